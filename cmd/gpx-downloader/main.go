@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
+	"syscall"
 	"time"
 
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
@@ -15,11 +18,11 @@ import (
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/logger"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/parser"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
 )
 
 const (
 	OUTPUT_PATH = "data-sources/gpx/"
-    DOWNLOAD_FILES = false
 )
 
 func ensureDownloadPath() (string, error) {
@@ -41,36 +44,45 @@ func ensureDownloadPath() (string, error) {
 }
 
 func main() {
+	retryFailed := flag.Bool("retry-failed", false, "only download files recorded as failed in the download manifest")
+	flag.Parse()
+
 	cfg, err := config.GetConfig("downloader")
 	log := logger.New(cfg.Logging, cfg.Env)
 	if err != nil {
 		log.Fatal().Caller().Err(err).Msg("Failed to get configurations")
 	}
+	cfg.Downloader.RetryFailed = *retryFailed
+
+	// SIGINT/SIGTERM (e.g. docker compose stop) cancel ctx. Every stage stops
+	// taking new work, lets in-flight writes finish and reports what it did.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	log.Info().Msgf("Database enabled: %v", cfg.Database.Enabled)
 
-    connStr := fmt.Sprintf(
-        "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-        cfg.Database.Host,
-        cfg.Database.Port,
-        cfg.Database.Username,
-        cfg.Database.Password,
-        cfg.Database.DatabaseName,
-    )
-
-    dbconfig,err := pgxpool.ParseConfig(connStr)
-    if err != nil {
-        log.Fatal().Err(err).Msgf("Failed to connect to database")
-    }
-
-    dbconfig.MaxConns = 20
-    dbconfig.MinConns = 10
-    dbconfig.MaxConnIdleTime = 10 * time.Hour
-    dbconfig.MaxConnLifetime = 10 * time.Hour
-    dbconfig.MaxConnLifetimeJitter = 11 * time.Hour
-
-    connPool, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
-    if err != nil {
+	connStr := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.Database.Host,
+		cfg.Database.Port,
+		cfg.Database.Username,
+		cfg.Database.Password,
+		cfg.Database.DatabaseName,
+	)
+
+	dbconfig, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed to connect to database")
+	}
+
+	dbconfig.MaxConns = 20
+	dbconfig.MinConns = 10
+	dbconfig.MaxConnIdleTime = 10 * time.Hour
+	dbconfig.MaxConnLifetime = 10 * time.Hour
+	dbconfig.MaxConnLifetimeJitter = 11 * time.Hour
+
+	connPool, err := pgxpool.NewWithConfig(ctx, dbconfig)
+	if err != nil {
 		log.Fatal().Err(err).Msg("Unable to create pgx pool")
 	}
 	log.Info().Msg("Database pool created!")
@@ -86,19 +98,30 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to ensure download path. Exiting")
 		return
 	}
-	database := db.New(connPool, installPath, log)
+	store, err := storage.New(cfg.Storage, installPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create file storage. Exiting")
+		return
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close file storage")
+		}
+	}()
+	log.Info().Msgf("Storing GPX files in %s storage", cfg.Storage.Type)
+	database := db.New(connPool, store, cfg.Analysis, log)
 
 	startTime := time.Now()
 
 	var csvFiles []models.CSVFile
-    if cfg.Env == "dev" {
-        csvFiles, _ = parser.StartParser("data-sources/csv", log)
-    } else {
-        csvFiles, _ = parser.StartParser("/data-sources/csv", log)
-    }
+	if cfg.Env == "dev" {
+		csvFiles, _ = parser.StartParser(ctx, "data-sources/csv", log)
+	} else {
+		csvFiles, _ = parser.StartParser(ctx, "/data-sources/csv", log)
+	}
 
 	if cfg.Database.Enabled {
-		database.SaveCSVFilesToDatabase(csvFiles)
+		database.SaveCSVFilesToDatabase(ctx, csvFiles)
 	}
 
 	var recordsToDownload []*models.DataRecord
@@ -107,28 +130,45 @@ func main() {
 			continue
 		}
 		recordsToDownload = append(recordsToDownload, csvFile.Data...)
-        log.Info().Msgf("File: %s | Records: %d", csvFile.FileName, len(csvFile.Data))
+		log.Info().Msgf("File: %s | Records: %d", csvFile.FileName, len(csvFile.Data))
 	}
-    log.Info().Msgf(
-        "Total records records: %d | Total files: %d | GPX Files: %d | CSV Files: %d",
-        len(recordsToDownload),
-        len(recordsToDownload) + len(csvFiles),
-        len(recordsToDownload),
-        len(csvFiles),
-    )
-
-    if len(recordsToDownload) < 1 {
+	log.Info().Msgf(
+		"Total records records: %d | Total files: %d | GPX Files: %d | CSV Files: %d",
+		len(recordsToDownload),
+		len(recordsToDownload)+len(csvFiles),
+		len(recordsToDownload),
+		len(csvFiles),
+	)
+
+	if len(recordsToDownload) < 1 {
 		log.Fatal().Msg("FAILED TO GET RECORDS TO DOWNLOAD. EXITIING")
 		return
 	}
 
-    if DOWNLOAD_FILES {
-	    downloader.StartDownload(recordsToDownload, installPath, log)
-    }
+	if cfg.Downloader.Enabled {
+		var knownHashes map[string]string
+		if cfg.Downloader.Incremental && cfg.Database.Enabled {
+			knownHashes, err = database.GetFileHashes(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load file hashes, existing files will only be checked by size")
+			}
+		}
+		summary := downloader.StartDownload(ctx, recordsToDownload, installPath, store, cfg.Downloader, knownHashes, log)
+		jsonPath, csvPath, err := downloader.NewReport(summary).Write(path.Dir(cfg.Logging.LogPath))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to write download report")
+		} else {
+			log.Info().Msgf("Download report written to %s and %s", jsonPath, csvPath)
+		}
+	}
 
 	if cfg.Database.Enabled {
-		database.SaveRecordsToDatabase(recordsToDownload)
+		database.SaveRecordsToDatabase(ctx, recordsToDownload)
 	}
 
+	if ctx.Err() != nil {
+		log.Warn().Msgf("Shutdown requested, stopped early! Total elapsed time: %v", time.Since(startTime))
+		return
+	}
 	log.Info().Msgf("All process completed! Total elapsed time: %v", time.Since(startTime))
 }
@@ -0,0 +1,81 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	NONE = "none"
+	GZIP = "gzip"
+	ZSTD = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Extension is the suffix added to the name of a file compressed with kind.
+func Extension(kind string) string {
+	switch kind {
+	case GZIP:
+		return ".gz"
+	case ZSTD:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// NewWriter compresses everything written to it into w. Close must be
+// called to flush the compressed stream; it does not close w.
+func NewWriter(w io.Writer, kind string) (io.WriteCloser, error) {
+	switch kind {
+	case GZIP:
+		return gzip.NewWriter(w), nil
+	case ZSTD:
+		return zstd.NewWriter(w)
+	case NONE, "":
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("Unknown compression %q", kind)
+	}
+}
+
+// NewReader detects gzip and zstd streams by their magic bytes and
+// decompresses them. Anything else is passed through unchanged, so callers
+// can read compressed and plain files alike.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(header, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
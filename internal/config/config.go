@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/utils"
 	"gopkg.in/yaml.v3"
@@ -28,10 +31,94 @@ type DatabaseConfig struct {
 	DatabaseName string `yaml:"DBName"`
 }
 
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"MaxAttempts"`
+	BaseDelay   time.Duration `yaml:"BaseDelay"`
+	MaxDelay    time.Duration `yaml:"MaxDelay"`
+	Jitter      float64       `yaml:"Jitter"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerSecond    float64       `yaml:"RequestsPerSecond"`
+	Burst                int           `yaml:"Burst"`
+	Adaptive             bool          `yaml:"Adaptive"`
+	MinRequestsPerSecond float64       `yaml:"MinRequestsPerSecond"`
+	RecoveryInterval     time.Duration `yaml:"RecoveryInterval"`
+}
+
+type AuthConfig struct {
+	Type          string `yaml:"Type"`
+	TokenPath     string `yaml:"TokenPath"`
+	Username      string `yaml:"Username"`
+	PasswordPath  string `yaml:"PasswordPath"`
+	LoginURL      string `yaml:"LoginURL"`
+	UsernameField string `yaml:"UsernameField"`
+	PasswordField string `yaml:"PasswordField"`
+	Token         string `yaml:"-"`
+	Password      string `yaml:"-"`
+	// TokenFile is where Token was read from, so a rotated token can be
+	// read again.
+	TokenFile string `yaml:"-"`
+}
+
+type ObjectStoreConfig struct {
+	Endpoint      string `yaml:"Endpoint"`
+	Region        string `yaml:"Region"`
+	UseSSL        bool   `yaml:"UseSSL"`
+	AccessKeyPath string `yaml:"AccessKeyPath"`
+	SecretKeyPath string `yaml:"SecretKeyPath"`
+	AccessKey     string `yaml:"-"`
+	SecretKey     string `yaml:"-"`
+}
+
+type StorageConfig struct {
+	Type         string            `yaml:"Type"`
+	Bucket       string            `yaml:"Bucket"`
+	KeyTemplate  string            `yaml:"KeyTemplate"`
+	Deduplicate  bool              `yaml:"Deduplicate"`
+	Layout       string            `yaml:"Layout"`
+	PrefixLength int               `yaml:"PrefixLength"`
+	Compression  string            `yaml:"Compression"`
+	ObjectStore  ObjectStoreConfig `yaml:"ObjectStore"`
+}
+
+type AnalysisConfig struct {
+	Enabled            bool    `yaml:"Enabled"`
+	TrackPoints        bool    `yaml:"TrackPoints"`
+	Tolerance          float64 `yaml:"Tolerance"`
+	ElevationThreshold float64 `yaml:"ElevationThreshold"`
+	MovingSpeed        float64 `yaml:"MovingSpeed"`
+	Simplification     string  `yaml:"Simplification"`
+	SimplifyTolerance  float64 `yaml:"SimplifyTolerance"`
+}
+
+type DownloaderConfig struct {
+	Enabled      bool              `yaml:"Enabled"`
+	BaseURL      string            `yaml:"BaseURL"`
+	URLTemplate  string            `yaml:"URLTemplate"`
+	Headers      map[string]string `yaml:"Headers"`
+	Timeout      time.Duration     `yaml:"Timeout"`
+	MaxSize      int64             `yaml:"MaxSize"`
+	Workers      int               `yaml:"Workers"`
+	Resume       bool              `yaml:"Resume"`
+	Incremental  bool              `yaml:"Incremental"`
+	Conditional  bool              `yaml:"Conditional"`
+	Hardlink     bool              `yaml:"Hardlink"`
+	ManifestPath string            `yaml:"ManifestPath"`
+	RetryFailed  bool              `yaml:"-"`
+	Retry        RetryConfig       `yaml:"Retry"`
+	RateLimit    RateLimitConfig   `yaml:"RateLimit"`
+	Auth         AuthConfig        `yaml:"Auth"`
+	ObjectStore  ObjectStoreConfig `yaml:"ObjectStore"`
+}
+
 type Config struct {
-	Env      string
-	Database DatabaseConfig `yaml:"Database"`
-	Logging  LoggingConfig  `yaml:"Logging"`
+	Env        string
+	Database   DatabaseConfig   `yaml:"Database"`
+	Downloader DownloaderConfig `yaml:"Downloader"`
+	Storage    StorageConfig    `yaml:"Storage"`
+	Analysis   AnalysisConfig   `yaml:"Analysis"`
+	Logging    LoggingConfig    `yaml:"Logging"`
 }
 
 func missingEnv(envName string) error {
@@ -45,6 +132,82 @@ func readFile(filePath string) ([]byte, error) {
 	return os.ReadFile(filePath)
 }
 
+// secretFile returns the path of a Docker secret. Outside prod the file with
+// the same name in the project's secrets directory is used instead.
+func secretFile(env, secretPath string) (string, error) {
+	if env == "prod" {
+		return secretPath, nil
+	}
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(execPath), "../../secrets", path.Base(secretPath)), nil
+}
+
+func readSecretFile(secretPath string) (string, error) {
+	secret, err := readFile(secretPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// readSecret reads a Docker secret, see secretFile.
+func readSecret(env, secretPath string) (string, error) {
+	secretPath, err := secretFile(env, secretPath)
+	if err != nil {
+		return "", err
+	}
+	return readSecretFile(secretPath)
+}
+
+func loadObjectStoreKeys(env string, cfg *ObjectStoreConfig) error {
+	if cfg.Endpoint == "" {
+		return errors.New("ObjectStore.Endpoint is required")
+	}
+	accessKey, err := readSecret(env, cfg.AccessKeyPath)
+	if err != nil {
+		return err
+	}
+	secretKey, err := readSecret(env, cfg.SecretKeyPath)
+	if err != nil {
+		return err
+	}
+	cfg.AccessKey = accessKey
+	cfg.SecretKey = secretKey
+	return nil
+}
+
+func loadAuthSecrets(env string, cfg *AuthConfig) error {
+	switch cfg.Type {
+	case "bearer":
+		tokenFile, err := secretFile(env, cfg.TokenPath)
+		if err != nil {
+			return err
+		}
+		cfg.TokenFile = tokenFile
+		token, err := cfg.ReloadToken()
+		if err != nil {
+			return err
+		}
+		cfg.Token = token
+	case "basic", "session":
+		password, err := readSecret(env, cfg.PasswordPath)
+		if err != nil {
+			return err
+		}
+		cfg.Password = password
+	}
+	return nil
+}
+
+// ReloadToken reads the bearer token again, for tokens that are rotated
+// while a run is in progress.
+func (cfg AuthConfig) ReloadToken() (string, error) {
+	return readSecretFile(cfg.TokenFile)
+}
+
 func getConfigFile(fileName, env string) ([]byte, error) {
 	configPath := path.Join("/config", fmt.Sprintf("%s.yml", fileName))
 	if env == "dev" {
@@ -61,6 +224,171 @@ func loadDefaultLogging() LoggingConfig {
 	return LoggingConfig{}
 }
 
+func loadDefaultDownloader() DownloaderConfig {
+	return DownloaderConfig{
+		URLTemplate: "{file}",
+		Headers:     map[string]string{},
+		Timeout:     30 * time.Second,
+		MaxSize:     64 << 20,
+		Workers:     16,
+		Resume:      true,
+		Retry: RetryConfig{
+			MaxAttempts: 5,
+			BaseDelay:   time.Second,
+			MaxDelay:    time.Minute,
+			Jitter:      0.2,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             10,
+			RecoveryInterval:  30 * time.Second,
+		},
+		Auth: AuthConfig{
+			Type:          "none",
+			UsernameField: "username",
+			PasswordField: "password",
+		},
+	}
+}
+
+func loadDefaultStorage() StorageConfig {
+	return StorageConfig{
+		Type:         "local",
+		KeyTemplate:  "{file}",
+		Layout:       "flat",
+		PrefixLength: 2,
+		Compression:  "none",
+	}
+}
+
+func loadDefaultAnalysis() AnalysisConfig {
+	return AnalysisConfig{
+		Enabled:            true,
+		TrackPoints:        true,
+		Tolerance:          0.1,
+		ElevationThreshold: 5,
+		MovingSpeed:        1,
+		Simplification:     "douglas-peucker",
+		SimplifyTolerance:  5,
+	}
+}
+
+func validateAnalysis(cfg AnalysisConfig) error {
+	if cfg.Tolerance < 0 {
+		return fmt.Errorf("Analysis.Tolerance %v must not be negative", cfg.Tolerance)
+	}
+	if cfg.ElevationThreshold < 0 {
+		return fmt.Errorf("Analysis.ElevationThreshold %v must not be negative", cfg.ElevationThreshold)
+	}
+	if cfg.MovingSpeed < 0 {
+		return fmt.Errorf("Analysis.MovingSpeed %v must not be negative", cfg.MovingSpeed)
+	}
+	switch cfg.Simplification {
+	case "none", "douglas-peucker", "visvalingam":
+	default:
+		return fmt.Errorf("Unknown Analysis.Simplification %q, expected none, douglas-peucker or visvalingam", cfg.Simplification)
+	}
+	if cfg.SimplifyTolerance < 0 {
+		return fmt.Errorf("Analysis.SimplifyTolerance %v must not be negative", cfg.SimplifyTolerance)
+	}
+	return nil
+}
+
+func validateStorage(cfg StorageConfig) error {
+	switch cfg.Layout {
+	case "flat":
+	case "user", "prefix", "month":
+		if cfg.Deduplicate {
+			return errors.New("Storage.Layout can not be used with Storage.Deduplicate, deduplicated files are stored by hash")
+		}
+		if cfg.Layout == "prefix" && cfg.PrefixLength < 1 {
+			return fmt.Errorf("Storage.PrefixLength must be at least 1, got %d", cfg.PrefixLength)
+		}
+	default:
+		return fmt.Errorf("Unknown Storage.Layout %q, expected flat, user, prefix or month", cfg.Layout)
+	}
+
+	switch cfg.Compression {
+	case "none", "gzip", "zstd":
+	default:
+		return fmt.Errorf("Unknown Storage.Compression %q, expected none, gzip or zstd", cfg.Compression)
+	}
+
+	switch cfg.Type {
+	case "local":
+		return nil
+	case "s3":
+		if cfg.Deduplicate {
+			return errors.New("Storage.Deduplicate is only supported for local storage")
+		}
+		if cfg.Bucket == "" {
+			return errors.New("Storage.Bucket is required for s3 storage")
+		}
+		if !strings.Contains(cfg.KeyTemplate, "{file}") && !strings.Contains(cfg.KeyTemplate, "{path}") {
+			return fmt.Errorf("Storage.KeyTemplate %q is missing the {file} or {path} placeholder", cfg.KeyTemplate)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unknown Storage.Type %q, expected local or s3", cfg.Type)
+	}
+}
+
+func validateDownloader(cfg DownloaderConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.BaseURL == "" && !strings.Contains(cfg.URLTemplate, "://") {
+		return errors.New("Downloader.BaseURL is required when the URL template is not absolute")
+	}
+	if !strings.Contains(cfg.URLTemplate, "{file}") {
+		return fmt.Errorf("Downloader.URLTemplate %q is missing the {file} placeholder", cfg.URLTemplate)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("Downloader.Timeout %v must not be negative", cfg.Timeout)
+	}
+	if cfg.MaxSize < 0 {
+		return fmt.Errorf("Downloader.MaxSize %d must not be negative", cfg.MaxSize)
+	}
+	if cfg.Workers < 1 {
+		return fmt.Errorf("Downloader.Workers must be at least 1, got %d", cfg.Workers)
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("Downloader.Retry.MaxAttempts must be at least 1, got %d", cfg.Retry.MaxAttempts)
+	}
+	if cfg.Retry.BaseDelay < 0 || cfg.Retry.MaxDelay < cfg.Retry.BaseDelay {
+		return fmt.Errorf("Downloader.Retry delays are invalid: BaseDelay %v, MaxDelay %v", cfg.Retry.BaseDelay, cfg.Retry.MaxDelay)
+	}
+	if cfg.Retry.Jitter < 0 || cfg.Retry.Jitter > 1 {
+		return fmt.Errorf("Downloader.Retry.Jitter must be between 0 and 1, got %v", cfg.Retry.Jitter)
+	}
+	if cfg.RateLimit.RequestsPerSecond < 0 || cfg.RateLimit.Burst < 0 || cfg.RateLimit.MinRequestsPerSecond < 0 {
+		return errors.New("Downloader.RateLimit values must not be negative")
+	}
+	return validateAuth(cfg.Auth)
+}
+
+func validateAuth(cfg AuthConfig) error {
+	switch cfg.Type {
+	case "", "none":
+		return nil
+	case "bearer":
+		if cfg.TokenPath == "" {
+			return errors.New("Downloader.Auth.TokenPath is required for bearer auth")
+		}
+		return nil
+	case "basic", "session":
+		if cfg.Username == "" || cfg.PasswordPath == "" {
+			return fmt.Errorf("Downloader.Auth.Username and PasswordPath are required for %s auth", cfg.Type)
+		}
+		if cfg.Type == "session" && cfg.LoginURL == "" {
+			return errors.New("Downloader.Auth.LoginURL is required for session auth")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unknown Downloader.Auth.Type %q, expected none, bearer, basic or session", cfg.Type)
+	}
+}
+
 func GetConfig(fileName string) (Config, error) {
 	config := Config{}
 	env, found := os.LookupEnv("APP_ENV")
@@ -69,6 +397,9 @@ func GetConfig(fileName string) (Config, error) {
 	}
 
 	config.Env = env
+	config.Downloader = loadDefaultDownloader()
+	config.Storage = loadDefaultStorage()
+	config.Analysis = loadDefaultAnalysis()
 
 	configBytes, err := getConfigFile(fileName, config.Env)
 	if err != nil {
@@ -79,30 +410,57 @@ func GetConfig(fileName string) (Config, error) {
 		return config, err
 	}
 
-    if config.Database.Enabled {
-        var passwordBytes []byte
-        if env == "prod" {
-            passwordBytes, err = readFile(config.Database.PasswordPath)
-            if err != nil {
-                return config, err
-            }
-        } else {
-            execPath, err := os.Executable()
-            if err != nil {
-                return config, err
-            }
-            pwdPath := path.Join(path.Dir(execPath), fmt.Sprintf("../../secrets/%s_password", fileName))
-            passwordBytes, err = readFile(pwdPath)
-            if err != nil {
-                return config, err
-            }
-        }
-        config.Database.Password = string(passwordBytes)
-    }
+	if config.Database.Enabled {
+		var passwordBytes []byte
+		if env == "prod" {
+			passwordBytes, err = readFile(config.Database.PasswordPath)
+			if err != nil {
+				return config, err
+			}
+		} else {
+			execPath, err := os.Executable()
+			if err != nil {
+				return config, err
+			}
+			pwdPath := path.Join(path.Dir(execPath), fmt.Sprintf("../../secrets/%s_password", fileName))
+			passwordBytes, err = readFile(pwdPath)
+			if err != nil {
+				return config, err
+			}
+		}
+		config.Database.Password = string(passwordBytes)
+	}
 
 	if valid, err := utils.ValidatePort(config.Database.Port); err != nil || !valid {
 		return config, err
 	}
 
+	if err := validateDownloader(config.Downloader); err != nil {
+		return config, err
+	}
+	if config.Downloader.Enabled {
+		if err := loadAuthSecrets(env, &config.Downloader.Auth); err != nil {
+			return config, err
+		}
+	}
+	if config.Downloader.Enabled && strings.HasPrefix(config.Downloader.BaseURL+config.Downloader.URLTemplate, "s3://") {
+		if err := loadObjectStoreKeys(env, &config.Downloader.ObjectStore); err != nil {
+			return config, err
+		}
+	}
+
+	if err := validateStorage(config.Storage); err != nil {
+		return config, err
+	}
+	if config.Storage.Type == "s3" {
+		if err := loadObjectStoreKeys(env, &config.Storage.ObjectStore); err != nil {
+			return config, err
+		}
+	}
+
+	if err := validateAnalysis(config.Analysis); err != nil {
+		return config, err
+	}
+
 	return config, nil
 }
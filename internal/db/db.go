@@ -1,65 +1,117 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"math"
-	"os"
-	"path"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/gpx"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/logger"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/sql/sqlc"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/ulid"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
 
+const (
+	MAX_FILE_ID_ATTEMPTS = 3
+	// UNIQUE_VIOLATION is the SQLSTATE of a unique constraint violation.
+	UNIQUE_VIOLATION = "23505"
+)
+
 type Database interface {
-	SaveCSVFilesToDatabase(csvFile []models.CSVFile)
-	SaveRecordsToDatabase(records []*models.DataRecord)
+	SaveCSVFilesToDatabase(ctx context.Context, csvFile []models.CSVFile)
+	SaveRecordsToDatabase(ctx context.Context, records []*models.DataRecord)
+	GetFileHashes(ctx context.Context) (map[string]string, error)
 }
 
 type BaseDatabase struct {
-	log         zerolog.Logger
-	installPath string
-	queries     *sqlc.Queries
+	log      zerolog.Logger
+	store    storage.Store
+	analysis config.AnalysisConfig
+	queries  *sqlc.Queries
 }
 
-func createContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 10*time.Minute)
+// createContext derives the context for a single write. It is detached from
+// the cancellation of parent so a write that has already started is allowed
+// to finish during shutdown; callers check parent between writes instead.
+func createContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(parent), 10*time.Minute)
 }
 
-func (db *BaseDatabase) saveFilesToDatabase(filesToInsert []sqlc.BulkInsertFilesParams) {
+func (db *BaseDatabase) readFile(ctx context.Context, record *models.DataRecord) ([]byte, error) {
+	file, err := db.store.Open(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// saveFilesToDatabase copies files into the database. A generated id that
+// collides with an existing one is regenerated and the copy retried, up to
+// MAX_FILE_ID_ATTEMPTS times; any other error is logged and the files are
+// not saved.
+func (db *BaseDatabase) saveFilesToDatabase(parent context.Context, filesToInsert []sqlc.BulkInsertFilesParams) {
+	if len(filesToInsert) == 0 {
+		return
+	}
 	db.log.Info().Msgf("Inserting %d files into the database...", len(filesToInsert))
-	ctx, cancel := createContext()
+	ctx, cancel := createContext(parent)
 	defer cancel()
-    defer func() {
-        if r := recover(); r != nil {
-            for idx := range filesToInsert {
-                newId, err := ulid.GenerateULID()
-                if err != nil {
-                    db.log.Error().Err(err).Msg("Failed to regenerate file id")
-                    return
-                }
-                filesToInsert[idx].ID = newId
-            }
-             db.saveFilesToDatabase(filesToInsert)
-        }
-    }()
-	rowsAffected, err := db.queries.BulkInsertFiles(ctx, filesToInsert)
+	for attempt := 1; ; attempt++ {
+		rowsAffected, err := db.queries.BulkInsertFiles(ctx, filesToInsert)
+		if err == nil {
+			db.log.Info().Msgf("Saved %d files | Rows affected: %d", len(filesToInsert), rowsAffected)
+			return
+		}
+		if !isFileIdCollision(err) || attempt == MAX_FILE_ID_ATTEMPTS {
+			db.log.Error().Err(err).Msgf("Failed to save %d files", len(filesToInsert))
+			return
+		}
+		db.log.Warn().Err(err).Msgf("File id collision, regenerating ids | Attempt: %d / %d", attempt, MAX_FILE_ID_ATTEMPTS)
+		for idx := range filesToInsert {
+			newId, err := ulid.GenerateULID()
+			if err != nil {
+				db.log.Error().Err(err).Msg("Failed to regenerate file id")
+				return
+			}
+			filesToInsert[idx].ID = newId
+		}
+	}
+}
+
+func isFileIdCollision(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION && pgErr.ConstraintName == "files_pkey"
+}
+
+// knownFiles returns the names of the files already in the database so a
+// rerun does not insert them again. If they can not be loaded every file is
+// treated as new.
+func (db *BaseDatabase) knownFiles(ctx context.Context) map[string]string {
+	hashes, err := db.GetFileHashes(ctx)
 	if err != nil {
-        db.log.Panic().Err(err).Send()
-	} else {
-		db.log.Info().Msgf("Saved %d files | Rows affected: %d", len(filesToInsert), rowsAffected)
+		db.log.Error().Err(err).Msg("Failed to load the files already in the database")
+		return nil
 	}
+	return hashes
 }
 
-func (db *BaseDatabase) saveUserToDatabase(userId string) {
-	ctx, cancel := createContext()
+func (db *BaseDatabase) saveUserToDatabase(parent context.Context, userId string) {
+	ctx, cancel := createContext(parent)
 	defer cancel()
 	if err := db.queries.InsertUser(ctx, userId); err != nil {
 		db.log.Error().Err(err).Send()
@@ -68,22 +120,33 @@ func (db *BaseDatabase) saveUserToDatabase(userId string) {
 	}
 }
 
-func (db *BaseDatabase) saveRecordsToDatabase(records []sqlc.BulkInsertRecordParams) {
+func (db *BaseDatabase) saveRecordsToDatabase(parent context.Context, records []sqlc.BulkInsertRecordParams) error {
 	db.log.Info().Msgf("saving %d records to database", len(records))
-	ctx, cancel := createContext()
+	ctx, cancel := createContext(parent)
 	defer cancel()
 	rowsAffected, err := db.queries.BulkInsertRecord(ctx, records)
 	if err != nil {
 		db.log.Error().Err(err).Send()
-	} else {
-		db.log.Info().Msgf("Saved %d records | Rows affected: %d", len(records), rowsAffected)
+		return err
 	}
+	db.log.Info().Msgf("Saved %d records | Rows affected: %d", len(records), rowsAffected)
+	return nil
 }
 
-func (db *BaseDatabase) SaveCSVFilesToDatabase(csvFiles []models.CSVFile) {
+func (db *BaseDatabase) SaveCSVFilesToDatabase(ctx context.Context, csvFiles []models.CSVFile) {
+	if err := ctx.Err(); err != nil {
+		db.log.Warn().Err(err).Msg("Skipping CSV file records, shutting down")
+		return
+	}
+
 	db.log.Info().Msgf("Preparing %d CSV file records", len(csvFiles))
+	known := db.knownFiles(ctx)
 	var insertParams []sqlc.BulkInsertFilesParams
 	for _, file := range csvFiles {
+		if _, found := known[file.FileName]; found {
+			db.log.Debug().Msgf("CSV file %s is already in the database", file.FileName)
+			continue
+		}
 		id, err := ulid.GenerateULID()
 		if err != nil {
 			db.log.Error().Err(err).Send()
@@ -98,16 +161,59 @@ func (db *BaseDatabase) SaveCSVFilesToDatabase(csvFiles []models.CSVFile) {
 		db.log.Info().Msgf("Files prepared: %d / %d", len(insertParams), len(csvFiles))
 	}
 
-	db.saveFilesToDatabase(insertParams)
+	db.saveFilesToDatabase(ctx, insertParams)
 }
 
-func (db *BaseDatabase) SaveRecordsToDatabase(records []*models.DataRecord) {
+// SaveRecordsToDatabase inserts records in batches. Cancelling ctx stops it
+// from starting another batch, but the batch being written is completed.
+func (db *BaseDatabase) SaveRecordsToDatabase(ctx context.Context, records []*models.DataRecord) {
+	if err := ctx.Err(); err != nil {
+		db.log.Warn().Err(err).Msg("Skipping records, shutting down")
+		return
+	}
+
+	// Records whose file was saved by an earlier run are already stored;
+	// inserting the file again would violate its unique file name.
+	known := db.knownFiles(ctx)
+	if len(known) > 0 {
+		newRecords := make([]*models.DataRecord, 0, len(records))
+		for _, record := range records {
+			if _, found := known[record.FileName]; !found {
+				newRecords = append(newRecords, record)
+			}
+		}
+		db.log.Info().Msgf("Records already in the database: %d | Records to save: %d", len(records)-len(newRecords), len(newRecords))
+		records = newRecords
+	}
+
 	batchSize := 1500
 	skipped := 0
 	recordCount := len(records)
 	batchCount := int(math.Ceil(float64(recordCount / batchSize)))
+	var duplicates, bytesSaved, mismatched atomic.Int64
+	var originalPoints, simplifiedPoints atomic.Int64
+	defer func() {
+		if originalPoints.Load() > 0 {
+			db.log.Info().Msgf("Simplified tracks from %d to %d points (%.1f%% removed)",
+				originalPoints.Load(),
+				simplifiedPoints.Load(),
+				100*float64(originalPoints.Load()-simplifiedPoints.Load())/float64(originalPoints.Load()),
+			)
+		}
+		if duplicates.Load() > 0 {
+			db.log.Info().Msgf("Duplicate files: %d | Raw data not stored again: %d bytes", duplicates.Load(), bytesSaved.Load())
+		}
+		if mismatched.Load() > 0 {
+			db.log.Warn().Msgf("Records whose CSV metrics do not match their GPX file: %d", mismatched.Load())
+		}
+	}()
 
 	for i := 0; i <= batchCount; i++ {
+		if err := ctx.Err(); err != nil {
+			db.log.Warn().Err(err).Msgf("Stopping before batch %d / %d, shutting down | Records saved: %d / %d", i, batchCount, min(skipped, recordCount), recordCount)
+			return
+		}
+
 		lower := skipped
 		upper := skipped + batchSize
 		if upper > recordCount {
@@ -118,35 +224,52 @@ func (db *BaseDatabase) SaveRecordsToDatabase(records []*models.DataRecord) {
 
         var preparedRecords []sqlc.BulkInsertRecordParams
         recordChan := make(chan sqlc.BulkInsertRecordParams, batchSize)
+        recordsDone := make(chan struct{})
 
         var preparedFiles []sqlc.BulkInsertFilesParams
         filesChan := make(chan sqlc.BulkInsertFilesParams, batchSize)
+        filesDone := make(chan struct{})
 
         usersChan := make(chan string, batchSize)
+        usersDone := make(chan struct{})
+
+		var pendingPoints []pendingTrackPoints
+		pointsChan := make(chan pendingTrackPoints, batchSize)
+		pointsDone := make(chan struct{})
 
         startTime := time.Now()
         go func() {
+			defer close(recordsDone)
 			for record := range recordChan {
 				preparedRecords = append(preparedRecords, record)
 			}
 		}()
 
 		go func() {
+			defer close(filesDone)
 			for file := range filesChan {
 				preparedFiles = append(preparedFiles, file)
 			}
 		}()
 
 		go func() {
+			defer close(pointsDone)
+			for points := range pointsChan {
+				pendingPoints = append(pendingPoints, points)
+			}
+		}()
+
+		go func() {
+			defer close(usersDone)
 			for user := range usersChan {
-				db.saveUserToDatabase(user)
+				db.saveUserToDatabase(ctx, user)
 			}
 		}()
 
 		var wg sync.WaitGroup
 		for _, record := range batchRecords {
+			wg.Add(1)
 			go func(record *models.DataRecord) {
-				wg.Add(1)
 				defer wg.Done()
 				fileId, err := ulid.GenerateULID()
 				if err != nil {
@@ -160,44 +283,86 @@ func (db *BaseDatabase) SaveRecordsToDatabase(records []*models.DataRecord) {
 					return
 				}
 
-				filePath := path.Join(db.installPath, record.FileName)
-				file, err := os.Open(filePath)
+				fileData, err := db.readFile(context.WithoutCancel(ctx), record)
 				if err != nil {
-					db.log.Error().Err(err).Msgf("Failed to open file %s", filePath)
+					db.log.Error().Err(err).Msgf("Faield to read file %s", db.store.Location(record))
 					return
 				}
 
-				fileHash, err := utils.GenerateFileHash(file)
-				if err != nil {
-					db.log.Error().Err(err).Msgf("Failed to generate file hash for file %s", file.Name())
-					return
+				// The downloader hashes files while streaming them; only
+				// fall back to hashing here for files it did not fetch.
+				fileHash := record.SHA512Sum
+				if fileHash == "" {
+					fileHash = utils.GenerateHash(fileData)
 				}
 
 				fileToInsert := sqlc.BulkInsertFilesParams{
 					ID:        fileId,
 					Filename:  record.FileName,
 					Sha512sum: fileHash,
+					Blobpath:  pgtype.Text{String: db.store.Key(record), Valid: true},
 				}
 
 				filesChan <- fileToInsert
 
-				fileData, err := os.ReadFile(filePath)
-				if err != nil {
-					db.log.Error().Err(err).Msgf("Faield to read file %s", filePath)
-					return
+				// Identical content is only kept once; the Files row of a
+				// duplicate points at the shared blob instead.
+				rawData := string(fileData)
+				if record.Duplicate {
+					rawData = ""
+					duplicates.Add(1)
+					bytesSaved.Add(int64(len(fileData)))
+				}
+
+				var metrics computedMetrics
+				var polyline pgtype.Text
+				if db.analysis.Enabled {
+					doc, err := gpx.Parse(bytes.NewReader(fileData))
+					if err != nil {
+						db.log.Warn().Err(err).Msgf("Failed to parse %s, metrics and track points are not stored", record.FileName)
+					} else {
+						metrics = db.computeMetrics(record, doc)
+						if metrics.Mismatched {
+							mismatched.Add(1)
+							db.log.Debug().Msgf("CSV metrics of %s do not match the GPX file: %s", record.FileName, metrics.MismatchedMetrics.String)
+						}
+						if db.analysis.TrackPoints {
+							pointsChan <- pendingTrackPoints{recordId: recordId, record: record}
+						}
+						if db.analysis.Simplification != gpx.SIMPLIFY_NONE {
+							simplified := doc.SimplifiedTrack(db.analysis.Simplification, db.analysis.SimplifyTolerance)
+							polyline = pgtype.Text{String: gpx.EncodePolyline(simplified), Valid: len(simplified) > 0}
+							originalPoints.Add(int64(doc.PointCount()))
+							simplifiedPoints.Add(int64(len(simplified)))
+							db.log.Debug().Msgf("Simplified %s from %d to %d points", record.FileName, doc.PointCount(), len(simplified))
+						}
+					}
 				}
 
 				recordToInsert := sqlc.BulkInsertRecordParams{
-					ID:            recordId,
-					Userid:        record.UserId,
-					Fileid:        fileId,
-					Duration:      record.Duration,
-					Distance:      record.Distance,
-					Ascent:        record.Ascent,
-					Descent:       record.Descent,
-					Elevationdiff: record.ElevationDiff,
-					Trails:        record.Trails,
-					Rawdata:       string(fileData),
+					ID:                    recordId,
+					Userid:                record.UserId,
+					Fileid:                fileId,
+					Duration:              record.Duration,
+					Distance:              record.Distance,
+					Ascent:                record.Ascent,
+					Descent:               record.Descent,
+					Elevationdiff:         record.ElevationDiff,
+					Trails:                record.Trails,
+					Rawdata:               rawData,
+					Computedduration:      metrics.Duration,
+					Computeddistance:      metrics.Distance,
+					Computedascent:        metrics.Ascent,
+					Computeddescent:       metrics.Descent,
+					Computedelevationdiff: metrics.ElevationDiff,
+					Mismatched:            metrics.Mismatched,
+					Mismatchedmetrics:     metrics.MismatchedMetrics,
+					Movingtime:            metrics.MovingTime,
+					Stoppedtime:           metrics.StoppedTime,
+					Averagemovingspeed:    metrics.AverageMovingSpeed,
+					Maxmovingspeed:        metrics.MaxMovingSpeed,
+					Pace:                  metrics.Pace,
+					Simplifiedpolyline:    polyline,
 				}
 				usersChan <- record.UserId
 				recordChan <- recordToInsert
@@ -208,15 +373,32 @@ func (db *BaseDatabase) SaveRecordsToDatabase(records []*models.DataRecord) {
 		close(recordChan)
 		close(filesChan)
 		close(usersChan)
+		close(pointsChan)
+		// The collectors may still be appending the last values they
+		// received; wait for them so nothing is dropped from the COPY, and
+		// for the users so the records referencing them can be inserted.
+		<-recordsDone
+		<-filesDone
+		<-usersDone
+		<-pointsDone
 
 		var dbwg sync.WaitGroup
 		dbwg.Add(1)
 		go func() {
 			defer dbwg.Done()
-			db.saveFilesToDatabase(preparedFiles)
+			db.saveFilesToDatabase(ctx, preparedFiles)
 			preparedFiles = nil
-			db.saveRecordsToDatabase(preparedRecords)
+			// Track points have no foreign key, so they are only stored
+			// once the records they belong to are.
+			if err := db.saveRecordsToDatabase(ctx, preparedRecords); err != nil {
+				if len(pendingPoints) > 0 {
+					db.log.Warn().Msgf("Skipping the track points of %d records that were not saved", len(pendingPoints))
+				}
+			} else {
+				db.saveTrackPointsToDatabase(ctx, pendingPoints)
+			}
 			preparedRecords = nil
+			pendingPoints = nil
 		}()
 		dbwg.Wait()
 
@@ -232,10 +414,29 @@ func (db *BaseDatabase) SaveRecordsToDatabase(records []*models.DataRecord) {
 	}
 }
 
-func New(db *pgxpool.Pool, installPath string, log logger.Logger) Database {
+// GetFileHashes returns the SHA-512 of every file known to the database,
+// keyed by file name.
+func (db *BaseDatabase) GetFileHashes(parent context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Minute)
+	defer cancel()
+	files, err := db.queries.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		hashes[file.Filename] = file.Sha512sum
+	}
+	db.log.Info().Msgf("Loaded %d file hashes from the database", len(hashes))
+	return hashes, nil
+}
+
+func New(db *pgxpool.Pool, store storage.Store, analysis config.AnalysisConfig, log logger.Logger) Database {
 	return &BaseDatabase{
-		log:         log.With().Str("serivce", "database").Logger(),
-		installPath: installPath,
-		queries:     sqlc.New(db),
+		log:      log.With().Str("serivce", "database").Logger(),
+		store:    store,
+		analysis: analysis,
+		queries:  sqlc.New(db),
 	}
 }
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/gpx"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// The CSV gives distance in kilometres and duration in hours; ascent,
+	// descent and elevation difference are in metres like the GPX file.
+	METRES_PER_KILOMETRE = 1000
+	SECONDS_PER_HOUR     = 3600
+	// Speeds are stored in km/h and pace in minutes per km.
+	KMH_PER_METRE_PER_SECOND = 3.6
+	SECONDS_PER_MINUTE       = 60
+)
+
+// computedMetrics are the CSV metrics recomputed from the GPX file. A metric
+// the file has no data for, such as duration without timestamps, is NULL.
+type computedMetrics struct {
+	Duration          pgtype.Float8
+	Distance          pgtype.Float8
+	Ascent            pgtype.Float8
+	Descent           pgtype.Float8
+	ElevationDiff     pgtype.Float8
+	Mismatched        bool
+	MismatchedMetrics pgtype.Text
+
+	MovingTime         pgtype.Float8
+	StoppedTime        pgtype.Float8
+	AverageMovingSpeed pgtype.Float8
+	MaxMovingSpeed     pgtype.Float8
+	Pace               pgtype.Float8
+}
+
+// computeMetrics compares the metrics of the parsed GPX file of record with
+// the CSV values. A metric is mismatched when it differs from the computed
+// one by more than the configured fraction of the computed value. Files
+// without track points, such as those with only routes or waypoints, have
+// nothing to compare and are left NULL.
+func (db *BaseDatabase) computeMetrics(record *models.DataRecord, doc *gpx.GPX) computedMetrics {
+	var metrics computedMetrics
+	if doc.PointCount() == 0 {
+		return metrics
+	}
+	stats := doc.Stats(db.analysis.ElevationThreshold)
+
+	var mismatched []string
+	compare := func(name string, field *pgtype.Float8, csvValue float32, computed float64) {
+		*field = float8(computed)
+		if math.Abs(float64(csvValue)-computed) > db.analysis.Tolerance*math.Abs(computed) {
+			mismatched = append(mismatched, name)
+		}
+	}
+
+	compare("distance", &metrics.Distance, record.Distance, stats.Distance/METRES_PER_KILOMETRE)
+	if stats.HasTime {
+		compare("duration", &metrics.Duration, record.Duration, stats.Duration.Seconds()/SECONDS_PER_HOUR)
+	}
+	if stats.HasElevation {
+		compare("ascent", &metrics.Ascent, record.Ascent, stats.Ascent)
+		compare("descent", &metrics.Descent, record.Descent, stats.Descent)
+		compare("elevation_diff", &metrics.ElevationDiff, record.ElevationDiff, stats.ElevationDiff)
+	}
+
+	if len(mismatched) > 0 {
+		metrics.Mismatched = true
+		metrics.MismatchedMetrics = pgtype.Text{String: strings.Join(mismatched, ","), Valid: true}
+	}
+
+	// Moving time needs timestamps; without them these stay NULL.
+	if stats.HasTime {
+		movement := doc.Movement(db.analysis.MovingSpeed / KMH_PER_METRE_PER_SECOND)
+		metrics.MovingTime = float8(movement.MovingTime.Seconds() / SECONDS_PER_HOUR)
+		metrics.StoppedTime = float8(movement.StoppedTime.Seconds() / SECONDS_PER_HOUR)
+		if movement.MovingTime > 0 {
+			metrics.AverageMovingSpeed = float8(movement.AverageSpeed * KMH_PER_METRE_PER_SECOND)
+			metrics.MaxMovingSpeed = float8(movement.MaxSpeed * KMH_PER_METRE_PER_SECOND)
+			metrics.Pace = float8(movement.Pace().Seconds() / SECONDS_PER_MINUTE)
+		}
+	}
+	return metrics
+}
+
+func float8(value float64) pgtype.Float8 {
+	return pgtype.Float8{Float64: value, Valid: true}
+}
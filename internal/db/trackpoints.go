@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/gpx"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/sql/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// trackPoints flattens the tracks of doc into TrackPoints rows. Segments are
+// numbered across all tracks of the file, points from 0 within a segment.
+func trackPoints(recordId string, doc *gpx.GPX) []sqlc.BulkInsertTrackPointsParams {
+	points := make([]sqlc.BulkInsertTrackPointsParams, 0, doc.PointCount())
+	segmentIndex := 0
+	for _, track := range doc.Tracks {
+		for _, segment := range track.Segments {
+			for sequence, point := range segment.Points {
+				points = append(points, sqlc.BulkInsertTrackPointsParams{
+					Recordid:     recordId,
+					Segmentindex: int32(segmentIndex),
+					Sequence:     int32(sequence),
+					Latitude:     point.Latitude,
+					Longitude:    point.Longitude,
+					Elevation:    pgtype.Float8{Float64: point.Elevation, Valid: point.HasElevation},
+					Recordedat:   pgtype.Timestamptz{Time: point.Time, Valid: !point.Time.IsZero()},
+				})
+			}
+			segmentIndex++
+		}
+	}
+	return points
+}
+
+// pendingTrackPoints is a record whose track points are stored once the
+// record itself has been saved.
+type pendingTrackPoints struct {
+	recordId string
+	record   *models.DataRecord
+}
+
+// saveTrackPointsToDatabase copies the track points of each record in turn.
+// The files are parsed again here rather than keeping the points of a whole
+// batch in memory, which for large tracks can run into gigabytes.
+func (db *BaseDatabase) saveTrackPointsToDatabase(parent context.Context, pending []pendingTrackPoints) {
+	if len(pending) == 0 {
+		return
+	}
+	db.log.Info().Msgf("Saving track points of %d records to database", len(pending))
+	var saved int64
+	for _, p := range pending {
+		rowsAffected, err := db.saveRecordTrackPoints(parent, p)
+		if err != nil {
+			db.log.Error().Err(err).Msgf("Failed to save track points of %s", p.record.FileName)
+			continue
+		}
+		saved += rowsAffected
+	}
+	db.log.Info().Msgf("Saved track points of %d records | Rows affected: %d", len(pending), saved)
+}
+
+func (db *BaseDatabase) saveRecordTrackPoints(parent context.Context, p pendingTrackPoints) (int64, error) {
+	ctx, cancel := createContext(parent)
+	defer cancel()
+	file, err := db.store.Open(ctx, p.record)
+	if err != nil {
+		return 0, err
+	}
+	doc, err := gpx.Parse(file)
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+	points := trackPoints(p.recordId, doc)
+	if len(points) == 0 {
+		return 0, nil
+	}
+	return db.queries.BulkInsertTrackPoints(ctx, points)
+}
@@ -0,0 +1,130 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+// authenticator adds the configured credentials to every request. Session
+// auth logs in once and relies on the client's cookie jar, which all workers
+// share. Bearer auth reads the token file again when a request is rejected,
+// so a rotated token is picked up.
+type authenticator struct {
+	cfg     config.AuthConfig
+	client  *http.Client
+	headers map[string]string
+
+	mu    sync.Mutex
+	token string
+	// generation counts logins and token reloads, so when several workers
+	// get a 401 for the same expired credentials only the first one
+	// refreshes them.
+	generation int
+}
+
+// newAuthenticator returns nil when no auth is configured. A nil
+// authenticator leaves requests untouched.
+func newAuthenticator(cfg config.AuthConfig, client *http.Client, headers map[string]string) *authenticator {
+	switch cfg.Type {
+	case "bearer", "basic", "session":
+		return &authenticator{cfg: cfg, client: client, headers: headers, token: cfg.Token}
+	default:
+		return nil
+	}
+}
+
+// Apply adds credentials to req and returns the login generation they
+// belong to.
+func (a *authenticator) Apply(req *http.Request) int {
+	if a == nil {
+		return 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.cfg.Type {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	case "basic":
+		req.SetBasicAuth(a.cfg.Username, a.cfg.Password)
+	}
+	return a.generation
+}
+
+// CanRefresh reports whether a rejected request is worth retrying after
+// refreshing the credentials. Passwords will not change.
+func (a *authenticator) CanRefresh() bool {
+	return a != nil && (a.cfg.Type == "session" || a.cfg.Type == "bearer")
+}
+
+// Refresh logs in again, or reads the bearer token again, unless another
+// worker already did so since the request made with generation was sent.
+func (a *authenticator) Refresh(ctx context.Context, generation int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.generation != generation {
+		return nil
+	}
+	if a.cfg.Type == "bearer" {
+		return a.reloadToken()
+	}
+	return a.login(ctx)
+}
+
+func (a *authenticator) reloadToken() error {
+	token, err := a.cfg.ReloadToken()
+	if err != nil {
+		return fmt.Errorf("Failed to read the bearer token again: %w", err)
+	}
+	a.token = token
+	a.generation++
+	return nil
+}
+
+// Login starts a session. It does nothing for auth types without one.
+func (a *authenticator) Login(ctx context.Context) error {
+	if a == nil || a.cfg.Type != "session" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.login(ctx)
+}
+
+// login posts the credentials as a form to the login URL. The session cookie
+// it sets is kept by the client's cookie jar.
+func (a *authenticator) login(ctx context.Context) error {
+	form := url.Values{}
+	form.Set(a.cfg.UsernameField, a.cfg.Username)
+	form.Set(a.cfg.PasswordField, a.cfg.Password)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.LoginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	for key, value := range a.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Login to %s failed: %w", a.cfg.LoginURL, newStatusError(resp))
+	}
+	a.generation++
+	return nil
+}
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+func TestBearerReloadsRotatedToken(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.Write([]byte(testGPX))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("rotated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.AuthConfig{Type: "bearer", Token: "expired", TokenFile: tokenFile}
+	fetcher := &httpFetcher{
+		client:  server.Client(),
+		limiter: newRateLimiter(config.RateLimitConfig{}),
+		auth:    newAuthenticator(cfg, server.Client(), nil),
+	}
+
+	res, err := fetcher.Fetch(context.Background(), server.URL+"/track.gpx", FetchOptions{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got := readBody(t, res); got != testGPX {
+		t.Errorf("body = %q, want %q", got, testGPX)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want the rejected one and a single retry", got)
+	}
+}
+
+func TestBearerRetriesOnlyOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("still-wrong"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.AuthConfig{Type: "bearer", Token: "expired", TokenFile: tokenFile}
+	fetcher := &httpFetcher{
+		client:  server.Client(),
+		limiter: newRateLimiter(config.RateLimitConfig{}),
+		auth:    newAuthenticator(cfg, server.Client(), nil),
+	}
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/track.gpx", FetchOptions{})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Fetch error = %v, want a 401 StatusError", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
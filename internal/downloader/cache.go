@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+const (
+	META_DIR = ".meta"
+)
+
+// errNotModified is returned by a conditional request answered with 304.
+var errNotModified = errors.New("File has not been modified since the last download")
+
+// cacheMetadata holds the validators the server sent with a file, stored as a
+// JSON sidecar so the next run can issue a conditional request.
+type cacheMetadata struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func metadataPath(installPath, fileName string) string {
+	return path.Join(installPath, META_DIR, fileName+".json")
+}
+
+// loadMetadata returns nil without an error when no sidecar exists.
+func loadMetadata(installPath, fileName string) (*cacheMetadata, error) {
+	data, err := os.ReadFile(metadataPath(installPath, fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta cacheMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// saveMetadata writes the validators the source sent, or removes a stale
+// sidecar when it sent none.
+func saveMetadata(installPath, fileName, source, etag, lastModified string) error {
+	filePath := metadataPath(installPath, fileName)
+	meta := cacheMetadata{
+		URL:          source,
+		ETag:         etag,
+		LastModified: lastModified,
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// conditionalOptions fills in the stored validators when the sidecar was
+// saved for the same source. Callers must check the file is still stored.
+func conditionalOptions(opts *FetchOptions, installPath, fileName, source string) error {
+	meta, err := loadMetadata(installPath, fileName)
+	if err != nil || meta == nil {
+		return err
+	}
+	if meta.URL != source {
+		return nil
+	}
+
+	opts.ETag = meta.ETag
+	opts.LastModified = meta.LastModified
+	return nil
+}
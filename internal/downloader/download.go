@@ -1,125 +1,474 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"math"
+	"io/fs"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
 	"path"
-	"sync"
+	"path/filepath"
+	"strings"
 
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/logger"
 	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/objectstore"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/utils"
 )
 
-const (
-    DOWNLOAD_URL = "api"
-	DOWNLOAD_BATCH_SIZE = 500
-)
+var ErrEmptyBody = fmt.Errorf("%w: response body is empty", ErrInvalidGPX)
+
+type downloader struct {
+	cfg         config.DownloaderConfig
+	httpFetcher *httpFetcher
+	s3Fetcher   *s3Fetcher
+	limiter     *rateLimiter
+	installPath string
+	store       storage.Store
+	knownHashes map[string]string
+	manifest    *manifest
+	log         logger.Logger
+}
+
+// buildURL expands the configured URL template for a record. The {file} and
+// {user_id} placeholders are path escaped before being substituted.
+func buildURL(cfg config.DownloaderConfig, record *models.DataRecord) string {
+	replacer := strings.NewReplacer(
+		"{file}", url.PathEscape(record.FileName),
+		"{user_id}", url.PathEscape(record.UserId),
+	)
+	return cfg.BaseURL + replacer.Replace(cfg.URLTemplate)
+}
 
-func downloadFile(fileName, outputPath string, log logger.Logger) error {
+// fetchFile performs a single download attempt. The body is written to a
+// .part file in the store's staging area and only committed to the store once
+// it has been fully copied, validated and synced to disk. With resuming enabled an
+// existing .part file is continued from where it stopped.
+func (d *downloader) fetchFile(ctx context.Context, record *models.DataRecord, attempt int) (statusCode int, err error) {
+	fileName := record.FileName
+	outputPath := d.installPath
+	log := d.log
 	if fileName == "" || outputPath == "" {
-		return errors.New("Filename or OutputPath is empty")
+		return 0, errors.New("Filename or OutputPath is empty")
 	}
 
-	filePath := path.Join(outputPath, fileName)
-	outputFile, err := os.Create(filePath)
+	source := buildURL(d.cfg, record)
+	fetcher, err := d.fetcherFor(source)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	partPath := d.store.StagingPath(record)
+	if linker, ok := fetcher.(Linker); ok && d.cfg.Hardlink {
+		err := d.linkFile(ctx, linker, record, source, partPath)
+		if err == nil || errors.Is(err, ErrInvalidGPX) || errors.Is(err, ErrSourceNotFound) || ctx.Err() != nil {
+			return 0, err
+		}
+		log.Warn().Err(err).Msgf("Failed to hard link %s, copying it instead", source)
+	}
+
+	outputFile, offset, err := d.openPartFile(partPath)
+	if err != nil {
+		return 0, err
 	}
 	defer func() {
 		outputFile.Close()
-		if err != nil {
-			log.Error().Err(err).Msgf("ERROR OCCURED! REMOVING FILE %s", outputFile.Name())
-			os.Remove(filePath)
+		if err == nil {
+			return
 		}
+		if errors.Is(err, errNotModified) {
+			os.Remove(partPath)
+			return
+		}
+		if ctx.Err() != nil && d.cfg.Resume {
+			log.Warn().Msgf("Download of %s cancelled, keeping %s to resume next run", fileName, partPath)
+			return
+		}
+		if d.cfg.Resume && isRetryable(err) {
+			log.Warn().Err(err).Msgf("Keeping %s to resume on the next attempt", partPath)
+			return
+		}
+		log.Error().Err(err).Msgf("ERROR OCCURED! REMOVING FILE %s", partPath)
+		os.Remove(partPath)
 	}()
 
-	log.Info().Msgf("Created file path at %s", filePath)
+	log.Info().Msgf("Created file path at %s | Attempt: %d | Offset: %d", partPath, attempt, offset)
 
-	resp, err := http.Get(fmt.Sprintf("%s%s", DOWNLOAD_URL, fileName))
+	opts := FetchOptions{Offset: offset}
+	if offset == 0 && d.cfg.Conditional {
+		if _, err := d.store.Stat(ctx, record); err == nil {
+			if err := conditionalOptions(&opts, outputPath, fileName, source); err != nil {
+				log.Warn().Err(err).Msgf("Failed to load cache metadata for %s, downloading unconditionally", fileName)
+			}
+		}
+	}
+
+	resp, err := fetcher.Fetch(ctx, source, opts)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if err := restartPartFile(outputFile); err != nil {
+			return 0, err
+		}
+		return 0, statusErr
+	}
+	if errors.Is(err, errNotModified) {
+		log.Info().Msgf("%s is unchanged at the source", fileName)
+		return 0, err
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			return errors.New("Failed to download file. WE GOT RATE LIMITED!!!")
+	if err := validateContentType(resp.ContentType); err != nil {
+		return 0, err
+	}
+
+	if offset > 0 && resp.Partial {
+		log.Info().Msgf("Resuming %s from byte %d", fileName, offset)
+	} else if offset > 0 {
+		log.Info().Msgf("Source ignored range request for %s, restarting download", fileName)
+		if err := restartPartFile(outputFile); err != nil {
+			return 0, err
+		}
+		offset = 0
+	}
+
+	maxSize := d.cfg.MaxSize
+	if maxSize > 0 && isIdentity(resp.ContentEncoding) && resp.ContentLength > 0 && offset+resp.ContentLength > maxSize {
+		return 0, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrTooLarge, fileName, offset+resp.ContentLength, maxSize)
+	}
+
+	hasher := sha512.New()
+	if offset > 0 {
+		if err := hashPrefix(outputFile, offset, hasher); err != nil {
+			return 0, err
+		}
+	}
+
+	counter := &countingReader{reader: resp.Body}
+	body, err := decodeBody(counter, resp.ContentEncoding)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	var src io.Reader = body
+	if maxSize > 0 {
+		// One byte past the limit is enough to tell the file is too large.
+		src = io.LimitReader(body, maxSize-offset+1)
+	}
+
+	copiedBytes, err := io.Copy(outputFile, io.TeeReader(src, hasher))
+	writtenBytes := offset + copiedBytes
+	if maxSize > 0 && writtenBytes > maxSize {
+		return 0, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, fileName, maxSize)
+	}
+	if resp.ContentLength >= 0 && counter.count < resp.ContentLength && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return 0, fmt.Errorf("%w: received %d of %d bytes of %s", ErrTruncated, counter.count, resp.ContentLength, fileName)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if writtenBytes == 0 {
+		return 0, ErrEmptyBody
+	}
+	if _, err := outputFile.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := validateGPX(outputFile); err != nil {
+		return 0, err
+	}
+
+	if err := outputFile.Sync(); err != nil {
+		return 0, err
+	}
+	if err := outputFile.Close(); err != nil {
+		return 0, err
+	}
+	record.SHA512Sum = hex.EncodeToString(hasher.Sum(nil))
+	record.Size = writtenBytes
+	if err := d.store.Commit(ctx, record, partPath); err != nil {
+		return 0, err
+	}
+
+	log.Info().Msgf("Written %d bytes to %s", writtenBytes, d.store.Location(record))
+
+	if d.cfg.Conditional {
+		if err := saveMetadata(outputPath, fileName, source, resp.ETag, resp.LastModified); err != nil {
+			log.Warn().Err(err).Msgf("Failed to save cache metadata for %s", fileName)
 		}
-		return fmt.Errorf("Failed to download file. Status code: %d", resp.StatusCode)
 	}
 
-	writtenBytes, err := io.Copy(outputFile, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// linkFile hard links a local source into place instead of copying it. The
+// link is made at partPath and validated there, so a bad source never shows
+// up under its final name. The linked file is only ever read, never opened
+// for writing, as that would modify the source too.
+func (d *downloader) linkFile(ctx context.Context, linker Linker, record *models.DataRecord, source, partPath string) error {
+	if err := os.Remove(partPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := linker.Link(ctx, source, partPath); err != nil {
+		return err
+	}
+
+	file, err := os.Open(partPath)
 	if err != nil {
+		os.Remove(partPath)
 		return err
 	}
+	defer file.Close()
 
-	log.Info().Msgf("Written %d bytes to %s", writtenBytes, outputFile.Name())
+	info, err := file.Stat()
+	if err == nil && info.Size() == 0 {
+		err = ErrEmptyBody
+	}
+	if err == nil && d.cfg.MaxSize > 0 && info.Size() > d.cfg.MaxSize {
+		err = fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrTooLarge, source, info.Size(), d.cfg.MaxSize)
+	}
+	if err == nil {
+		err = validateGPX(file)
+	}
+	var fileHash string
+	if err == nil {
+		fileHash, err = utils.GenerateFileHash(file)
+	}
+	if err == nil {
+		file.Close()
+		record.SHA512Sum = fileHash
+		record.Size = info.Size()
+		err = d.store.Commit(ctx, record, partPath)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return err
+	}
 
+	d.log.Info().Msgf("Linked %s to %s", source, d.store.Location(record))
 	return nil
 }
 
-func downloadBatch(installPath string, records []*models.DataRecord, size int, log logger.Logger) {
-	skipped := 0
-	fileCount := len(records)
-	batchCount := int(math.Ceil(float64(fileCount / size)))
-
-	for i := 0; i <= batchCount; i++ {
-		lower := skipped
-		upper := skipped + size
-		if upper > fileCount {
-			upper = fileCount
-		}
-		batchRecords := records[lower:upper]
-		skipped += size
-
-		errChan := make(chan error)
-		doneChan := make(chan int)
-		processErrors := make([]error, 0)
-
-		go func() {
-			for {
-				select {
-				case err := <-errChan:
-					processErrors = append(processErrors, err)
-				case <-doneChan:
-					close(errChan)
-					close(doneChan)
-					return
-				}
-			}
-		}()
-
-		var wg sync.WaitGroup
+// downloadFile fetches a record, retrying transient failures according to
+// the configured retry policy. It returns the number of attempts made and
+// the HTTP status of the last one, or 0 when there was none.
+func (d *downloader) downloadFile(ctx context.Context, record *models.DataRecord) (int, int, error) {
+	retryCfg := d.cfg.Retry
+	var statusCode int
+	var err error
+	for attempt := 1; attempt <= retryCfg.MaxAttempts; attempt++ {
+		statusCode, err = d.fetchFile(ctx, record, attempt)
+		if statusCode == 0 {
+			statusCode = statusCodeOf(err)
+		}
+		if err == nil || errors.Is(err, errNotModified) || ctx.Err() != nil {
+			return attempt, statusCode, err
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+			d.limiter.Throttle()
+			d.log.Warn().Msgf("Rate limited while downloading %s | Request rate now %.2f/s", record.FileName, d.limiter.Rate())
+		}
+		if !isRetryable(err) {
+			d.log.Error().Err(err).Msgf("Attempt %d / %d for %s failed, not retrying", attempt, retryCfg.MaxAttempts, record.FileName)
+			return attempt, statusCode, err
+		}
+		if attempt == retryCfg.MaxAttempts {
+			break
+		}
 
-		for _, record := range batchRecords{
-            wg.Add(1)
-			go func(instalLPath string, record *models.DataRecord) {
-				defer wg.Done()
-				if err := downloadFile(record.FileName, installPath, log); err != nil {
-					errChan <- err
-					log.Error().Err(err).Msgf("Failed to download %s", record.FileName)
-				} else {
-					log.Info().Msgf("Downloaded %s successfully", record.FileName)
-				}
-			}(installPath, record)
+		delay := retryDelay(retryCfg, attempt, err)
+		d.log.Warn().Err(err).Msgf("Attempt %d / %d for %s failed, retrying in %v", attempt, retryCfg.MaxAttempts, record.FileName, delay)
+		if err := sleep(ctx, delay); err != nil {
+			return attempt, statusCode, err
 		}
+	}
 
-		wg.Wait()
+	return retryCfg.MaxAttempts, statusCode, fmt.Errorf("Giving up on %s after %d attempts: %w", record.FileName, retryCfg.MaxAttempts, err)
+}
 
-		doneChan <- 0
-		log.Info().Msgf("Batch Download completed | Successful downloads: %d  | Errors occurred: %d", i, len(processErrors))
-		if len(processErrors) > 0 {
-			for _, err := range processErrors {
-				log.Error().Err(err).Send()
-			}
+// openPartFile opens the .part file for a download. When resuming is enabled
+// an existing file is opened for appending and its size returned as the
+// offset to resume from; otherwise it is truncated.
+func (d *downloader) openPartFile(partPath string) (*os.File, int64, error) {
+	if !d.cfg.Resume {
+		file, err := os.Create(partPath)
+		return file, 0, err
+	}
+
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, offset, nil
+}
+
+// hashPrefix feeds the first size bytes of a resumed .part file into hasher
+// and leaves the file positioned at its end, ready for appending.
+func hashPrefix(file *os.File, size int64, hasher hash.Hash) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(hasher, file, size); err != nil {
+		return err
+	}
+	_, err := file.Seek(size, io.SeekStart)
+	return err
+}
+
+func restartPartFile(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return err
+}
+
+// cleanPartFiles removes the .part files left behind by an interrupted run.
+func cleanPartFiles(installPath string) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(installPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), storage.PART_SUFFIX) {
+			return nil
 		}
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// isDownloaded reports whether a record's file is already in the store with
+// a non-zero size and, when the database knows its hash, matching content.
+func (d *downloader) isDownloaded(ctx context.Context, record *models.DataRecord) (bool, error) {
+	size, err := d.store.Stat(ctx, record)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if size == 0 {
+		return false, nil
+	}
+
+	hash, known := d.knownHashes[record.FileName]
+	if !known {
+		return true, nil
+	}
+
+	file, err := d.store.Open(ctx, record)
+	if err != nil {
+		return false, err
 	}
+	defer file.Close()
+
+	fileHash, err := utils.GenerateReaderHash(file)
+	if err != nil {
+		return false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(fileHash), []byte(hash)) != 1 {
+		d.log.Warn().Msgf("%s does not match its recorded SHA-512, downloading it again", d.store.Location(record))
+		return false, nil
+	}
+
+	record.SHA512Sum = hash
+	record.Size = size
+	return true, nil
 }
 
-func StartDownload(csvRecords []*models.DataRecord, installPath string, log logger.Logger) {
-	downloadBatch(installPath, csvRecords, DOWNLOAD_BATCH_SIZE, log)
+// StartDownload downloads every record into store. installPath holds the
+// partial downloads, cache metadata and manifest. knownHashes maps file names
+// to the SHA-512 recorded in the database and is only consulted in
+// incremental mode; it may be nil.
+func StartDownload(ctx context.Context, csvRecords []*models.DataRecord, installPath string, store storage.Store, cfg config.DownloaderConfig, knownHashes map[string]string, log logger.Logger) Summary {
+	limiter := newRateLimiter(cfg.RateLimit)
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.Auth.Type == "session" {
+		// cookiejar.New only fails for a bad PublicSuffixList.
+		client.Jar, _ = cookiejar.New(nil)
+	}
+	auth := newAuthenticator(cfg.Auth, client, cfg.Headers)
+	d := &downloader{
+		cfg: cfg,
+		httpFetcher: &httpFetcher{
+			client:  client,
+			headers: cfg.Headers,
+			limiter: limiter,
+			auth:    auth,
+		},
+		s3Fetcher:   &s3Fetcher{limiter: limiter},
+		limiter:     limiter,
+		installPath: installPath,
+		store:       store,
+		knownHashes: knownHashes,
+		log:         log,
+	}
+
+	if err := auth.Login(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to log in, downloads will try again when they are rejected")
+	} else if cfg.Auth.Type == "session" {
+		log.Info().Msgf("Logged in to %s", cfg.Auth.LoginURL)
+	}
+
+	if cfg.ObjectStore.Endpoint != "" {
+		client, err := objectstore.New(cfg.ObjectStore)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to create object store client for %s", cfg.ObjectStore.Endpoint)
+		} else {
+			d.s3Fetcher.client = client
+		}
+	}
+
+	if cfg.Resume {
+		log.Info().Msgf("Resuming is enabled, %s files from a previous run will be continued", storage.PART_SUFFIX)
+	} else {
+		removed, err := cleanPartFiles(installPath)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to clean stale %s files in %s", storage.PART_SUFFIX, installPath)
+		} else if removed > 0 {
+			log.Info().Msgf("Removed %d stale %s files from a previous run", removed, storage.PART_SUFFIX)
+		}
+	}
+
+	manifestPath := cfg.ManifestPath
+	if manifestPath == "" {
+		manifestPath = path.Join(installPath, MANIFEST_FILE)
+	}
+	if m, err := openManifest(manifestPath); err != nil {
+		log.Error().Err(err).Msgf("Failed to open download manifest %s, progress will not be saved", manifestPath)
+	} else {
+		d.manifest = m
+		defer func() {
+			if err := d.manifest.Close(); err != nil {
+				log.Error().Err(err).Msgf("Failed to close download manifest %s", manifestPath)
+			}
+		}()
+	}
+	if cfg.RetryFailed {
+		log.Info().Msg("Only retrying files that failed in a previous run")
+	}
+
+	return d.run(ctx, csvRecords)
 }
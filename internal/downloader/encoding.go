@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrTruncated is returned when a body ends before the Content-Length the
+// source announced. It is retried, resuming from the bytes already written.
+var ErrTruncated = errors.New("Response body is truncated")
+
+// ErrTooLarge is returned when a file is larger than Downloader.MaxSize.
+var ErrTooLarge = fmt.Errorf("%w: file exceeds the maximum size", ErrInvalidGPX)
+
+// countingReader counts the bytes read through it, before any decoding, so
+// they can be checked against the Content-Length.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func isIdentity(contentEncoding string) bool {
+	contentEncoding = strings.ToLower(strings.TrimSpace(contentEncoding))
+	return contentEncoding == "" || contentEncoding == "identity"
+}
+
+// decodeBody undoes a gzip, deflate or zstd Content-Encoding. Deflate is
+// meant to be zlib wrapped, but some servers send raw deflate data, so both
+// are accepted. zstd is what objects stored with Storage.Compression zstd are
+// tagged with, so a bucket written by the S3 sink can be read back as a
+// source.
+func decodeBody(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	if isIdentity(contentEncoding) {
+		return io.NopCloser(body), nil
+	}
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGPX, err.Error())
+		}
+		return reader, nil
+	case "deflate":
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGPX, err.Error())
+		}
+		if isZlibHeader(header) {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidGPX, err.Error())
+			}
+			return reader, nil
+		}
+		return flate.NewReader(buffered), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGPX, err.Error())
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported Content-Encoding %q", ErrInvalidGPX, contentEncoding)
+	}
+}
+
+// isZlibHeader checks the compression method and header checksum of
+// RFC 1950.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
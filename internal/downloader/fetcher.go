@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ErrSourceNotFound is returned by fetchers when the requested file does not
+// exist at the source. It is never retried.
+var ErrSourceNotFound = errors.New("File not found at source")
+
+// FetchOptions carry hints a fetcher may honour. A fetcher that can not
+// resume from Offset returns the whole body with Partial unset.
+type FetchOptions struct {
+	Offset       int64
+	ETag         string
+	LastModified string
+}
+
+// FetchResponse is the body of a fetched file plus whatever metadata the
+// source provided. Body is still encoded as ContentEncoding says and
+// ContentLength counts the encoded bytes; it is -1 when unknown. StatusCode
+// is 0 for sources that do not speak HTTP.
+type FetchResponse struct {
+	Body            io.ReadCloser
+	StatusCode      int
+	ContentType     string
+	ContentEncoding string
+	ContentLength   int64
+	Partial         bool
+	ETag            string
+	LastModified    string
+}
+
+// Fetcher retrieves a single GPX file from a source location. Fetch returns
+// errNotModified when the validators in opts show the file is unchanged.
+type Fetcher interface {
+	Fetch(ctx context.Context, source string, opts FetchOptions) (*FetchResponse, error)
+}
+
+// Linker is implemented by fetchers that can place a file at dst without
+// copying it, e.g. by hard linking from a local share.
+type Linker interface {
+	Link(ctx context.Context, source, dst string) error
+}
+
+// fetcherFor picks a fetcher by the scheme of the expanded source URL.
+// Sources without a scheme are treated as paths in a local directory.
+func (d *downloader) fetcherFor(source string) (Fetcher, error) {
+	parsed, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+		return d.httpFetcher, nil
+	case "s3":
+		return d.s3Fetcher, nil
+	case "file":
+		return fileFetcher{}, nil
+	case "":
+		return dirFetcher{}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported download source scheme %q", parsed.Scheme)
+	}
+}
+
+// openLocal opens a local file as a FetchResponse, seeking to opts.Offset so
+// local sources resume just like HTTP range requests.
+func openLocal(ctx context.Context, filePath string, opts FetchOptions) (*FetchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%w: %s is a directory", ErrSourceNotFound, filePath)
+	}
+
+	res := &FetchResponse{
+		Body:          file,
+		ContentLength: info.Size(),
+	}
+	if opts.Offset > 0 && opts.Offset < info.Size() {
+		if _, err := file.Seek(opts.Offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		res.ContentLength = info.Size() - opts.Offset
+		res.Partial = true
+	}
+	return res, nil
+}
+
+// fileFetcher reads file:// URLs.
+type fileFetcher struct{}
+
+func (fileFetcher) Fetch(ctx context.Context, source string, opts FetchOptions) (*FetchResponse, error) {
+	parsed, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return openLocal(ctx, filepath.FromSlash(parsed.Path), opts)
+}
+
+// dirFetcher reads plain paths, typically a directory on a mounted share or
+// USB drive. The source still comes from the URL template, so escaped
+// placeholders are unescaped first.
+type dirFetcher struct{}
+
+func (dirFetcher) localPath(source string) (string, error) {
+	filePath, err := url.PathUnescape(source)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(filePath), nil
+}
+
+func (f dirFetcher) Fetch(ctx context.Context, source string, opts FetchOptions) (*FetchResponse, error) {
+	filePath, err := f.localPath(source)
+	if err != nil {
+		return nil, err
+	}
+	return openLocal(ctx, filePath, opts)
+}
+
+func (f dirFetcher) Link(ctx context.Context, source, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filePath, err := f.localPath(source)
+	if err != nil {
+		return err
+	}
+	err = os.Link(filePath, dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, filePath)
+	}
+	return err
+}
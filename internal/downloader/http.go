@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// httpFetcher downloads over HTTP(S). Every request waits on the shared rate
+// limiter first.
+type httpFetcher struct {
+	client  *http.Client
+	headers map[string]string
+	limiter *rateLimiter
+	auth    *authenticator
+}
+
+// do sends the request for source. When the session has expired or the
+// bearer token was rejected it refreshes the credentials and sends the
+// request once more.
+func (f *httpFetcher) do(ctx context.Context, source string, opts FetchOptions) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		req, err := f.newRequest(ctx, source, opts)
+		if err != nil {
+			return nil, err
+		}
+		generation := f.auth.Apply(req)
+
+		if err := f.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || retried || !f.auth.CanRefresh() {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		if err := f.auth.Refresh(ctx, generation); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, source string, opts FetchOptions) (*FetchResponse, error) {
+	resp, err := f.do(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return handleResponse(resp, source, opts)
+}
+
+func (f *httpFetcher) newRequest(ctx context.Context, source string, opts FetchOptions) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range f.headers {
+		req.Header.Set(key, value)
+	}
+	// Setting Accept-Encoding stops the transport from decoding gzip
+	// itself, so the body can be checked against Content-Length before it
+	// is decoded. Ranges are only meaningful for the unencoded content.
+	if opts.Offset > 0 {
+		req.Header.Set("Accept-Encoding", "identity")
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", opts.Offset))
+	} else {
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		if opts.ETag != "" {
+			req.Header.Set("If-None-Match", opts.ETag)
+		}
+		if opts.LastModified != "" {
+			req.Header.Set("If-Modified-Since", opts.LastModified)
+		}
+	}
+	return req, nil
+}
+
+// handleResponse turns a response into a FetchResponse, or into an error for
+// anything but a full or correctly resumed body.
+func handleResponse(resp *http.Response, source string, opts FetchOptions) (*FetchResponse, error) {
+	res := &FetchResponse{
+		Body:            resp.Body,
+		StatusCode:      resp.StatusCode,
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+		ContentLength:   resp.ContentLength,
+		ETag:            resp.Header.Get("ETag"),
+		LastModified:    resp.Header.Get("Last-Modified"),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return res, nil
+	case resp.StatusCode == http.StatusPartialContent && opts.Offset > 0:
+		start, err := parseContentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if start != opts.Offset {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Server resumed %s at byte %d, expected %d", source, start, opts.Offset)
+		}
+		if !isIdentity(res.ContentEncoding) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Server sent a %s encoded range of %s", res.ContentEncoding, source)
+		}
+		res.Partial = true
+		return res, nil
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		return nil, errNotModified
+	default:
+		resp.Body.Close()
+		return nil, newStatusError(resp)
+	}
+}
+
+// parseContentRangeStart returns the first byte position of a
+// "bytes start-end/size" Content-Range header.
+func parseContentRangeStart(value string) (int64, error) {
+	var start, end int64
+	var size string
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/%s", &start, &end, &size); err != nil {
+		return 0, fmt.Errorf("Invalid Content-Range header %q: %w", value, err)
+	}
+	return start, nil
+}
@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/jsonl"
+)
+
+type State string
+
+const (
+	STATE_PENDING     State = "pending"
+	STATE_DOWNLOADING State = "downloading"
+	STATE_DONE        State = "done"
+	STATE_FAILED      State = "failed"
+
+	MANIFEST_FILE = "manifest.jsonl"
+)
+
+type ManifestEntry struct {
+	FileName  string    `json:"file_name"`
+	State     State     `json:"state"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// manifest is an append-only JSON-lines log of per-file download state. The
+// last line for a file wins, so a crash can at worst lose the line being
+// written. It is compacted to one line per file each time it is opened.
+type manifest struct {
+	mu      sync.Mutex
+	path    string
+	log     *jsonl.Log[*ManifestEntry]
+	entries map[string]*ManifestEntry
+}
+
+func openManifest(manifestPath string) (*manifest, error) {
+	log, entries, err := jsonl.Open(manifestPath, func(entry *ManifestEntry) string {
+		if entry == nil {
+			return ""
+		}
+		return entry.FileName
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest{
+		path:    manifestPath,
+		log:     log,
+		entries: entries,
+	}, nil
+}
+
+// Get returns a copy of the entry for fileName. A nil manifest knows nothing.
+func (m *manifest) Get(fileName string) (ManifestEntry, bool) {
+	if m == nil {
+		return ManifestEntry{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, found := m.entries[fileName]
+	if !found {
+		return ManifestEntry{}, false
+	}
+	return *entry, true
+}
+
+// Update records a new state for fileName, adding attempts to its running
+// attempt count. A nil manifest ignores updates.
+func (m *manifest) Update(fileName string, state State, attempts int, lastErr error) error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, found := m.entries[fileName]
+	if !found {
+		entry = &ManifestEntry{FileName: fileName}
+		m.entries[fileName] = entry
+	}
+	entry.State = state
+	entry.Attempts += attempts
+	entry.UpdatedAt = time.Now()
+	if lastErr != nil {
+		entry.LastError = lastErr.Error()
+	} else if state == STATE_DONE {
+		entry.LastError = ""
+	}
+
+	return m.log.Append(entry)
+}
+
+func (m *manifest) Close() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.log.Close()
+}
@@ -0,0 +1,229 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+)
+
+type Status string
+
+const (
+	STATUS_SUCCEEDED Status = "succeeded"
+	STATUS_FAILED    Status = "failed"
+	STATUS_SKIPPED   Status = "skipped"
+	STATUS_UNCHANGED Status = "unchanged"
+	STATUS_INVALID   Status = "invalid"
+	STATUS_CANCELLED Status = "cancelled"
+)
+
+type Result struct {
+	Record     *models.DataRecord
+	Status     Status
+	StatusCode int
+	Attempts   int
+	Duration   time.Duration
+	Error      error
+}
+
+type Summary struct {
+	Started    time.Time
+	Total      int
+	Succeeded  int
+	Failed     int
+	Skipped    int
+	Unchanged  int
+	Invalid    []string
+	Cancelled  int
+	Duplicates int
+	BytesSaved int64
+	Bytes      int64
+	Elapsed    time.Duration
+	Errors     []error
+	Results    []Result
+}
+
+func (s *Summary) add(res Result) {
+	s.Results = append(s.Results, res)
+	switch res.Status {
+	case STATUS_SUCCEEDED:
+		s.Succeeded++
+		s.Bytes += res.Record.Size
+		if res.Record.Duplicate {
+			s.Duplicates++
+			s.BytesSaved += res.Record.Size
+		}
+	case STATUS_FAILED:
+		s.Failed++
+		s.Errors = append(s.Errors, res.Error)
+	case STATUS_SKIPPED:
+		s.Skipped++
+	case STATUS_UNCHANGED:
+		s.Unchanged++
+	case STATUS_INVALID:
+		s.Invalid = append(s.Invalid, res.Record.FileName)
+	case STATUS_CANCELLED:
+		s.Cancelled++
+	}
+}
+
+// manifestState maps a download result onto the state persisted in the
+// manifest. Cancelled downloads go back to pending so the next run picks
+// them up again.
+func manifestState(status Status) State {
+	switch status {
+	case STATUS_SUCCEEDED, STATUS_SKIPPED, STATUS_UNCHANGED:
+		return STATE_DONE
+	case STATUS_FAILED, STATUS_INVALID:
+		return STATE_FAILED
+	default:
+		return STATE_PENDING
+	}
+}
+
+func (d *downloader) process(ctx context.Context, id int, record *models.DataRecord) Result {
+	if ctx.Err() != nil {
+		return Result{Record: record, Status: STATUS_CANCELLED, Error: ctx.Err()}
+	}
+
+	if d.cfg.Incremental {
+		downloaded, err := d.isDownloaded(ctx, record)
+		if err != nil {
+			d.log.Warn().Err(err).Msgf("Failed to check existing file for %s, downloading it again", record.FileName)
+		} else if downloaded {
+			d.log.Debug().Msgf("Worker %d skipped %s, already downloaded", id, record.FileName)
+			return Result{Record: record, Status: STATUS_SKIPPED}
+		}
+	}
+
+	if err := d.manifest.Update(record.FileName, STATE_DOWNLOADING, 0, nil); err != nil {
+		d.log.Error().Err(err).Msgf("Failed to update manifest for %s", record.FileName)
+	}
+
+	attempts, statusCode, err := d.downloadFile(ctx, record)
+	if ctx.Err() != nil && err != nil {
+		d.log.Warn().Err(err).Msgf("Worker %d stopped while downloading %s", id, record.FileName)
+		return Result{Record: record, Status: STATUS_CANCELLED, Attempts: attempts, StatusCode: statusCode, Error: err}
+	}
+	if errors.Is(err, errNotModified) {
+		return Result{Record: record, Status: STATUS_UNCHANGED, Attempts: attempts, StatusCode: statusCode}
+	}
+	if errors.Is(err, ErrInvalidGPX) {
+		d.log.Error().Err(err).Msgf("Worker %d rejected %s", id, record.FileName)
+		return Result{Record: record, Status: STATUS_INVALID, Attempts: attempts, StatusCode: statusCode, Error: err}
+	}
+	if err != nil {
+		d.log.Error().Err(err).Msgf("Worker %d failed to download %s", id, record.FileName)
+		return Result{Record: record, Status: STATUS_FAILED, Attempts: attempts, StatusCode: statusCode, Error: err}
+	}
+	d.log.Info().Msgf("Worker %d downloaded %s successfully", id, record.FileName)
+	return Result{Record: record, Status: STATUS_SUCCEEDED, Attempts: attempts, StatusCode: statusCode}
+}
+
+func (d *downloader) worker(ctx context.Context, id int, jobs <-chan *models.DataRecord, results chan<- Result, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for record := range jobs {
+		startTime := time.Now()
+		res := d.process(ctx, id, record)
+		res.Duration = time.Since(startTime)
+		if err := d.manifest.Update(record.FileName, manifestState(res.Status), res.Attempts, res.Error); err != nil {
+			d.log.Error().Err(err).Msgf("Failed to update manifest for %s", record.FileName)
+		}
+		results <- res
+	}
+}
+
+// shouldQueue decides from the manifest whether a record still needs work.
+// With RetryFailed only files that failed last time are queued. Otherwise
+// everything is, including files already marked done: in incremental mode
+// process skips them after checking their size and hash, and in conditional
+// mode they are revalidated with the server.
+func (d *downloader) shouldQueue(record *models.DataRecord) bool {
+	if !d.cfg.RetryFailed {
+		return true
+	}
+	entry, found := d.manifest.Get(record.FileName)
+	return found && entry.State == STATE_FAILED
+}
+
+// run feeds every record to a fixed pool of workers and collects a summary.
+// Records without a file name, or whose file name was already queued, are
+// skipped. Once ctx is cancelled no new records are queued and the remaining
+// ones are counted as cancelled.
+func (d *downloader) run(ctx context.Context, records []*models.DataRecord) Summary {
+	startTime := time.Now()
+	summary := Summary{Started: startTime, Total: len(records)}
+
+	workerCount := d.cfg.Workers
+	if workerCount > len(records) {
+		workerCount = len(records)
+	}
+
+	jobs := make(chan *models.DataRecord, workerCount)
+	results := make(chan Result, workerCount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go d.worker(ctx, i+1, jobs, results, &wg)
+	}
+
+	go func() {
+		seen := make(map[string]bool, len(records))
+	feed:
+		for idx, record := range records {
+			if record == nil || record.FileName == "" || seen[record.FileName] || !d.shouldQueue(record) {
+				results <- Result{Record: record, Status: STATUS_SKIPPED}
+				continue
+			}
+			seen[record.FileName] = true
+			select {
+			case jobs <- record:
+			case <-ctx.Done():
+				for _, remaining := range records[idx:] {
+					results <- Result{Record: remaining, Status: STATUS_CANCELLED}
+				}
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	processed := 0
+	for res := range results {
+		summary.add(res)
+		processed++
+		if processed%1000 == 0 {
+			d.log.Info().Msgf("Download progress: %d / %d", processed, summary.Total)
+		}
+	}
+
+	summary.Elapsed = time.Since(startTime)
+	d.log.Info().Msgf(
+		"Download completed | Total: %d | Fetched: %d | Failed: %d | Invalid: %d | Skipped: %d | Unchanged: %d | Cancelled: %d | Elapsed time: %v",
+		summary.Total,
+		summary.Succeeded,
+		summary.Failed,
+		len(summary.Invalid),
+		summary.Skipped,
+		summary.Unchanged,
+		summary.Cancelled,
+		summary.Elapsed,
+	)
+	if summary.Duplicates > 0 {
+		d.log.Info().Msgf("Duplicates: %d | Space saved: %d bytes", summary.Duplicates, summary.BytesSaved)
+	}
+	for _, err := range summary.Errors {
+		d.log.Error().Err(err).Send()
+	}
+	for _, fileName := range summary.Invalid {
+		d.log.Error().Msgf("Invalid GPX file rejected: %s", fileName)
+	}
+
+	return summary
+}
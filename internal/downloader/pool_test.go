@@ -0,0 +1,113 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/logger"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
+)
+
+// etagServer serves testGPX with a fixed ETag and records the If-None-Match
+// header of every request.
+func etagServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.Write([]byte(testGPX))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), conditions...)
+	}
+}
+
+func startTestDownload(t *testing.T, installPath string, cfg config.DownloaderConfig, knownHashes map[string]string) Summary {
+	t.Helper()
+	cfg.URLTemplate = "{file}"
+	cfg.Workers = 2
+	cfg.Timeout = 5 * time.Second
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1}
+	store := storage.NewLocal(installPath, storage.NewLayout(config.StorageConfig{Layout: "flat"}), "none")
+	log := logger.New(config.LoggingConfig{LogLevel: "error"}, "test")
+	records := []*models.DataRecord{{FileName: "track.gpx"}}
+	return StartDownload(context.Background(), records, installPath, store, cfg, knownHashes, log)
+}
+
+func TestRerunRevalidatesDoneFilesWhenConditional(t *testing.T) {
+	server, conditions := etagServer(t)
+	installPath := t.TempDir()
+	cfg := config.DownloaderConfig{BaseURL: server.URL + "/", Conditional: true}
+
+	if summary := startTestDownload(t, installPath, cfg, nil); summary.Succeeded != 1 {
+		t.Fatalf("first run succeeded %d, want 1", summary.Succeeded)
+	}
+	summary := startTestDownload(t, installPath, cfg, nil)
+	if summary.Unchanged != 1 || summary.Skipped != 0 {
+		t.Errorf("second run unchanged %d, skipped %d, want the file reported as unchanged", summary.Unchanged, summary.Skipped)
+	}
+	if got := conditions(); len(got) != 2 || got[1] != `"v1"` {
+		t.Errorf("If-None-Match headers = %q, want the second request to be conditional", got)
+	}
+}
+
+func TestRerunChecksHashOfDoneFilesWhenIncremental(t *testing.T) {
+	server, conditions := etagServer(t)
+	installPath := t.TempDir()
+	cfg := config.DownloaderConfig{BaseURL: server.URL + "/", Incremental: true}
+
+	if summary := startTestDownload(t, installPath, cfg, nil); summary.Succeeded != 1 {
+		t.Fatalf("first run succeeded %d, want 1", summary.Succeeded)
+	}
+	if summary := startTestDownload(t, installPath, cfg, nil); summary.Skipped != 1 {
+		t.Errorf("second run skipped %d, want 1", summary.Skipped)
+	}
+
+	// The manifest marks the file done, but a corrupted copy must not
+	// pass the hash check.
+	stored := filepath.Join(installPath, "track.gpx")
+	if err := os.WriteFile(stored, []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	summary := startTestDownload(t, installPath, cfg, map[string]string{"track.gpx": "not the hash of corrupted"})
+	if summary.Succeeded != 1 {
+		t.Errorf("third run succeeded %d, skipped %d, want the corrupted file downloaded again", summary.Succeeded, summary.Skipped)
+	}
+	if got := len(conditions()); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestRerunDownloadsDoneFilesWithoutIncrementalOrConditional(t *testing.T) {
+	server, conditions := etagServer(t)
+	installPath := t.TempDir()
+	cfg := config.DownloaderConfig{BaseURL: server.URL + "/"}
+
+	startTestDownload(t, installPath, cfg, nil)
+	if summary := startTestDownload(t, installPath, cfg, nil); summary.Succeeded != 1 {
+		t.Errorf("second run succeeded %d, want 1", summary.Succeeded)
+	}
+	if got := len(conditions()); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
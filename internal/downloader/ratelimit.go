@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+// THROTTLE_WINDOW is how long after halving the rate further 429s are
+// ignored. Requests already in flight when the remote starts rate limiting
+// all fail together, and that burst should only halve the rate once.
+const THROTTLE_WINDOW = 2 * time.Second
+
+// rateLimiter is a token bucket shared by every download. In adaptive mode
+// the refill rate is halved whenever the remote rate limits us and recovers
+// by a tenth of the configured rate every RecoveryInterval.
+type rateLimiter struct {
+	mu sync.Mutex
+
+	rate     float64
+	maxRate  float64
+	minRate  float64
+	burst    float64
+	tokens   float64
+	adaptive bool
+
+	recoveryInterval time.Duration
+	lastRefill       time.Time
+	lastAdjust       time.Time
+	lastThrottle     time.Time
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	if cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	minRate := cfg.MinRequestsPerSecond
+	if minRate <= 0 {
+		minRate = cfg.RequestsPerSecond / 16
+	}
+	if minRate > cfg.RequestsPerSecond {
+		minRate = cfg.RequestsPerSecond
+	}
+
+	now := time.Now()
+	return &rateLimiter{
+		rate:             cfg.RequestsPerSecond,
+		maxRate:          cfg.RequestsPerSecond,
+		minRate:          minRate,
+		burst:            burst,
+		tokens:           burst,
+		adaptive:         cfg.Adaptive,
+		recoveryInterval: cfg.RecoveryInterval,
+		lastRefill:       now,
+		lastAdjust:       now,
+	}
+}
+
+// refill must be called with mu held.
+func (l *rateLimiter) refill(now time.Time) {
+	if l.adaptive && l.rate < l.maxRate && l.recoveryInterval > 0 {
+		for now.Sub(l.lastAdjust) >= l.recoveryInterval && l.rate < l.maxRate {
+			l.rate += l.maxRate / 10
+			l.lastAdjust = l.lastAdjust.Add(l.recoveryInterval)
+		}
+		if l.rate > l.maxRate {
+			l.rate = l.maxRate
+		}
+	}
+
+	l.tokens += now.Sub(l.lastRefill).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.lastRefill = now
+}
+
+// Wait blocks until a token is available or ctx is done. A nil limiter never
+// blocks.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	for {
+		l.mu.Lock()
+		l.refill(time.Now())
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Throttle halves the current rate after the remote answered 429, at most
+// once per THROTTLE_WINDOW. It is a no-op unless the limiter runs in adaptive
+// mode.
+func (l *rateLimiter) Throttle() {
+	if l == nil || !l.adaptive {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastThrottle) < THROTTLE_WINDOW {
+		return
+	}
+	l.refill(now)
+	l.rate /= 2
+	if l.rate < l.minRate {
+		l.rate = l.minRate
+	}
+	l.lastAdjust = now
+	l.lastThrottle = now
+}
+
+// Rate reports the current refill rate in requests per second.
+func (l *rateLimiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+func TestThrottleHalvesOncePerBurst(t *testing.T) {
+	limiter := newRateLimiter(config.RateLimitConfig{
+		RequestsPerSecond:    16,
+		Adaptive:             true,
+		MinRequestsPerSecond: 1,
+		RecoveryInterval:     time.Hour,
+	})
+
+	// Every worker with a request in flight gets the 429 at once.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Throttle()
+		}()
+	}
+	wg.Wait()
+	if got := limiter.Rate(); got != 8 {
+		t.Fatalf("rate after one burst = %v, want 8", got)
+	}
+
+	limiter.mu.Lock()
+	limiter.lastThrottle = limiter.lastThrottle.Add(-THROTTLE_WINDOW)
+	limiter.mu.Unlock()
+	limiter.Throttle()
+	if got := limiter.Rate(); got != 4 {
+		t.Errorf("rate after a later 429 = %v, want 4", got)
+	}
+}
@@ -0,0 +1,124 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+const (
+	REPORT_PREFIX = "download-report"
+)
+
+// ReportEntry is one file in a run report. It is a row of the CSV report.
+type ReportEntry struct {
+	FileName   string `json:"file_name" csv:"file_name"`
+	UserId     string `json:"user_id" csv:"user_id"`
+	Status     Status `json:"status" csv:"status"`
+	StatusCode int    `json:"status_code,omitempty" csv:"status_code"`
+	Bytes      int64  `json:"bytes" csv:"bytes"`
+	DurationMs int64  `json:"duration_ms" csv:"duration_ms"`
+	Attempts   int    `json:"attempts" csv:"attempts"`
+	Error      string `json:"error,omitempty" csv:"error"`
+}
+
+type ReportTotals struct {
+	Total          int     `json:"total"`
+	Succeeded      int     `json:"succeeded"`
+	Failed         int     `json:"failed"`
+	Invalid        int     `json:"invalid"`
+	Skipped        int     `json:"skipped"`
+	Unchanged      int     `json:"unchanged"`
+	Cancelled      int     `json:"cancelled"`
+	Duplicates     int     `json:"duplicates"`
+	Bytes          int64   `json:"bytes"`
+	BytesSaved     int64   `json:"bytes_saved"`
+	ElapsedMs      int64   `json:"elapsed_ms"`
+	FilesPerSecond float64 `json:"files_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+}
+
+// Report describes a whole download run, so runs can be compared and
+// failures handed back to the data provider.
+type Report struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Totals     ReportTotals  `json:"totals"`
+	Files      []ReportEntry `json:"files"`
+}
+
+func NewReport(summary Summary) Report {
+	report := Report{
+		StartedAt:  summary.Started,
+		FinishedAt: summary.Started.Add(summary.Elapsed),
+		Totals: ReportTotals{
+			Total:      summary.Total,
+			Succeeded:  summary.Succeeded,
+			Failed:     summary.Failed,
+			Invalid:    len(summary.Invalid),
+			Skipped:    summary.Skipped,
+			Unchanged:  summary.Unchanged,
+			Cancelled:  summary.Cancelled,
+			Duplicates: summary.Duplicates,
+			Bytes:      summary.Bytes,
+			BytesSaved: summary.BytesSaved,
+			ElapsedMs:  summary.Elapsed.Milliseconds(),
+		},
+		Files: make([]ReportEntry, 0, len(summary.Results)),
+	}
+	if seconds := summary.Elapsed.Seconds(); seconds > 0 {
+		report.Totals.FilesPerSecond = float64(summary.Succeeded) / seconds
+		report.Totals.BytesPerSecond = float64(summary.Bytes) / seconds
+	}
+
+	for _, res := range summary.Results {
+		entry := ReportEntry{
+			Status:     res.Status,
+			StatusCode: res.StatusCode,
+			DurationMs: res.Duration.Milliseconds(),
+			Attempts:   res.Attempts,
+		}
+		if res.Record != nil {
+			entry.FileName = res.Record.FileName
+			entry.UserId = res.Record.UserId
+			entry.Bytes = res.Record.Size
+		}
+		if res.Error != nil {
+			entry.Error = res.Error.Error()
+		}
+		report.Files = append(report.Files, entry)
+	}
+	return report
+}
+
+// Write saves the report as JSON and CSV in dir, named after the time the
+// run started. It returns the paths written.
+func (r Report) Write(dir string) (string, string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	baseName := path.Join(dir, REPORT_PREFIX+"-"+r.StartedAt.Format("20060102-150405"))
+
+	jsonPath := baseName + ".json"
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+		return "", "", err
+	}
+
+	csvPath := baseName + ".csv"
+	file, err := os.Create(csvPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	if err := gocsv.MarshalFile(&r.Files, file); err != nil {
+		return "", "", err
+	}
+	return jsonPath, csvPath, file.Close()
+}
@@ -0,0 +1,208 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/logger"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test"><trk><name>Test</name><trkseg>
+<trkpt lat="25.0330" lon="121.5654"><ele>10</ele></trkpt>
+<trkpt lat="25.0340" lon="121.5664"><ele>12</ele></trkpt>
+</trkseg></trk></gpx>
+`
+
+// newTestDownloader returns a downloader with resuming enabled that fetches
+// from baseURL into a local store in a temporary directory.
+func newTestDownloader(t *testing.T, baseURL string) *downloader {
+	t.Helper()
+	installPath := t.TempDir()
+	cfg := config.DownloaderConfig{
+		BaseURL:     baseURL,
+		URLTemplate: "{file}",
+		Resume:      true,
+		Retry: config.RetryConfig{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    time.Millisecond,
+		},
+	}
+	limiter := newRateLimiter(cfg.RateLimit)
+	return &downloader{
+		cfg: cfg,
+		httpFetcher: &httpFetcher{
+			client:  &http.Client{Timeout: 5 * time.Second},
+			limiter: limiter,
+		},
+		s3Fetcher:   &s3Fetcher{limiter: limiter},
+		limiter:     limiter,
+		installPath: installPath,
+		store:       storage.NewLocal(installPath, storage.NewLayout(config.StorageConfig{Layout: "flat"}), "none"),
+		log:         logger.New(config.LoggingConfig{LogLevel: "error"}, "test"),
+	}
+}
+
+// writePart leaves content behind as the .part file of record, as an
+// interrupted download would.
+func writePart(t *testing.T, d *downloader, record *models.DataRecord, content string) string {
+	t.Helper()
+	partPath := d.store.StagingPath(record)
+	if err := os.WriteFile(partPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return partPath
+}
+
+func readStored(t *testing.T, d *downloader, record *models.DataRecord) string {
+	t.Helper()
+	file, err := d.store.Open(context.Background(), record)
+	if err != nil {
+		t.Fatalf("stored file missing: %v", err)
+	}
+	defer file.Close()
+	var content bytes.Buffer
+	if _, err := content.ReadFrom(file); err != nil {
+		t.Fatal(err)
+	}
+	return content.String()
+}
+
+// rangeServer serves testGPX with Range support and records the Range header
+// of every request.
+func rangeServer(t *testing.T, ranges *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		http.ServeContent(w, r, "track.gpx", time.Time{}, strings.NewReader(testGPX))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResumeAppendsPartialContent(t *testing.T) {
+	var ranges []string
+	server := rangeServer(t, &ranges)
+	d := newTestDownloader(t, server.URL+"/")
+	record := &models.DataRecord{FileName: "track.gpx"}
+	offset := 40
+	writePart(t, d, record, testGPX[:offset])
+
+	statusCode, err := d.fetchFile(context.Background(), record, 1)
+	if err != nil {
+		t.Fatalf("fetchFile: %v", err)
+	}
+	if statusCode != http.StatusPartialContent {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusPartialContent)
+	}
+	if want := fmt.Sprintf("bytes=%d-", offset); len(ranges) != 1 || ranges[0] != want {
+		t.Errorf("Range headers = %q, want [%q]", ranges, want)
+	}
+	if got := readStored(t, d, record); got != testGPX {
+		t.Errorf("stored content = %q, want %q", got, testGPX)
+	}
+	if record.Size != int64(len(testGPX)) {
+		t.Errorf("record size = %d, want %d", record.Size, len(testGPX))
+	}
+}
+
+func TestResumeRestartsWhenRangeIgnored(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.Write([]byte(testGPX))
+	}))
+	defer server.Close()
+	d := newTestDownloader(t, server.URL+"/")
+	record := &models.DataRecord{FileName: "track.gpx"}
+	writePart(t, d, record, testGPX[:40])
+
+	statusCode, err := d.fetchFile(context.Background(), record, 1)
+	if err != nil {
+		t.Fatalf("fetchFile: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusOK)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=40-" {
+		t.Errorf("Range headers = %q, want [\"bytes=40-\"]", ranges)
+	}
+	if got := readStored(t, d, record); got != testGPX {
+		t.Errorf("stored content = %q, want the full file without the old prefix", got)
+	}
+}
+
+func TestResumeTruncatesOnRangeNotSatisfiable(t *testing.T) {
+	var ranges []string
+	server := rangeServer(t, &ranges)
+	d := newTestDownloader(t, server.URL+"/")
+	record := &models.DataRecord{FileName: "track.gpx"}
+	// A .part file longer than the source can only be stale.
+	partPath := writePart(t, d, record, testGPX+strings.Repeat("x", 10))
+
+	_, err := d.fetchFile(context.Background(), record, 1)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("fetchFile error = %v, want status 416", err)
+	}
+	info, err := os.Stat(partPath)
+	if err != nil {
+		t.Fatalf("part file should be kept for the retry: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("part file size = %d, want 0 after a 416", info.Size())
+	}
+
+	attempts, statusCode, err := d.downloadFile(context.Background(), record)
+	if err != nil {
+		t.Fatalf("downloadFile after 416: %v", err)
+	}
+	if attempts != 1 || statusCode != http.StatusOK {
+		t.Errorf("attempts = %d, status code = %d, want 1 and %d", attempts, statusCode, http.StatusOK)
+	}
+	if got := readStored(t, d, record); got != testGPX {
+		t.Errorf("stored content = %q, want %q", got, testGPX)
+	}
+	if ranges[len(ranges)-1] != "" {
+		t.Errorf("retry sent Range %q, want a full request", ranges[len(ranges)-1])
+	}
+}
+
+func TestResumeRejectsMismatchedContentRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(testGPX)-1, len(testGPX)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(testGPX))
+	}))
+	defer server.Close()
+	d := newTestDownloader(t, server.URL+"/")
+	record := &models.DataRecord{FileName: "track.gpx"}
+	partPath := writePart(t, d, record, testGPX[:40])
+
+	_, err := d.fetchFile(context.Background(), record, 1)
+	if err == nil || !strings.Contains(err.Error(), "expected 40") {
+		t.Fatalf("fetchFile error = %v, want a Content-Range mismatch", err)
+	}
+	if _, err := d.store.Stat(context.Background(), record); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file was stored despite the mismatched range: %v", err)
+	}
+	if _, err := os.Stat(partPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("part file kept after a mismatched range: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+// StatusError is returned when the remote answers with a non-successful HTTP
+// status. RetryAfter is only set when the response carried a usable
+// Retry-After header.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return "Failed to download file. Rate limited (status code 429)"
+	}
+	return fmt.Sprintf("Failed to download file. Status code: %d", e.StatusCode)
+}
+
+// statusCodeOf returns the HTTP status behind err, or 0 when it did not come
+// from a response.
+func statusCodeOf(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	if errors.Is(err, errNotModified) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		statusErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return statusErr
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: a number of seconds
+// or an HTTP date. Anything unparsable or in the past yields zero.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// isRetryable reports whether a failed attempt is worth repeating. It does
+// not look at context errors: http.Client timeouts wrap
+// context.DeadlineExceeded too, and those are transient. Callers check their
+// own context instead.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusNotFound:
+			return false
+		case statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode == http.StatusRequestTimeout,
+			statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			return true
+		default:
+			return statusErr.StatusCode >= 500
+		}
+	}
+
+	if errors.Is(err, ErrTruncated) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// backoff returns the delay before the next attempt, doubling from BaseDelay
+// up to MaxDelay and spreading it by +/- Jitter.
+func backoff(cfg config.RetryConfig, attempt int) time.Duration {
+	delay := float64(cfg.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if delay > float64(cfg.MaxDelay) {
+		delay = float64(cfg.MaxDelay)
+	}
+	if cfg.Jitter > 0 {
+		delay += delay * cfg.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// sleep waits for d, returning early with the context's error if it is
+// cancelled first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryDelay picks how long to wait after a failed attempt. A Retry-After
+// sent by the server takes precedence over our own backoff when it is longer,
+// but is capped at MaxDelay so a server can not park a worker indefinitely.
+func retryDelay(cfg config.RetryConfig, attempt int, err error) time.Duration {
+	delay := backoff(cfg, attempt)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = max(delay, min(statusErr.RetryAfter, cfg.MaxDelay))
+	}
+	return delay
+}
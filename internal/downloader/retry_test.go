@@ -0,0 +1,30 @@
+package downloader
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cfg := config.RetryConfig{BaseDelay: time.Second, MaxDelay: time.Minute}
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{"backoff", 3, 0, 4 * time.Second},
+		{"shorter Retry-After", 3, time.Second, 4 * time.Second},
+		{"longer Retry-After", 1, 30 * time.Second, 30 * time.Second},
+		{"Retry-After beyond MaxDelay", 1, 24 * time.Hour, time.Minute},
+	}
+	for _, tt := range tests {
+		err := &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: tt.retryAfter}
+		if got := retryDelay(cfg, tt.attempt, err); got != tt.want {
+			t.Errorf("%s: retryDelay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
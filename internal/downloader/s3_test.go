@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/objectstore"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/objectstore/objectstoretest"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/storage"
+)
+
+func newTestS3Fetcher(t *testing.T, server *objectstoretest.Server) *s3Fetcher {
+	t.Helper()
+	client, err := objectstore.New(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &s3Fetcher{client: client, limiter: newRateLimiter(config.RateLimitConfig{})}
+}
+
+func readBody(t *testing.T, res *FetchResponse) string {
+	t.Helper()
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestS3FetcherGet(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	server.Put("gpx", "user-1/track.gpx", objectstoretest.Object{Data: []byte(testGPX), ContentType: "application/gpx+xml"})
+	fetcher := newTestS3Fetcher(t, server)
+
+	res, err := fetcher.Fetch(context.Background(), "s3://gpx/user-1/track.gpx", FetchOptions{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || res.Partial {
+		t.Errorf("status code = %d, partial = %v, want a full 200", res.StatusCode, res.Partial)
+	}
+	if res.ContentType != "application/gpx+xml" || res.ContentLength != int64(len(testGPX)) || res.ETag == "" {
+		t.Errorf("Content-Type %q, length %d, ETag %q", res.ContentType, res.ContentLength, res.ETag)
+	}
+	if got := readBody(t, res); got != testGPX {
+		t.Errorf("body = %q, want %q", got, testGPX)
+	}
+}
+
+func TestS3FetcherRangedGet(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	server.Put("gpx", "track.gpx", objectstoretest.Object{Data: []byte(testGPX)})
+	fetcher := newTestS3Fetcher(t, server)
+
+	res, err := fetcher.Fetch(context.Background(), "s3://gpx/track.gpx", FetchOptions{Offset: 40})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if res.StatusCode != http.StatusPartialContent || !res.Partial {
+		t.Errorf("status code = %d, partial = %v, want a partial 206", res.StatusCode, res.Partial)
+	}
+	if got := readBody(t, res); got != testGPX[40:] {
+		t.Errorf("body = %q, want %q", got, testGPX[40:])
+	}
+	if ranges := server.Ranges(); len(ranges) == 0 || ranges[0] != "bytes=40-" {
+		t.Errorf("Range headers = %q, want bytes=40-", ranges)
+	}
+}
+
+func TestS3FetcherMissingKey(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	fetcher := newTestS3Fetcher(t, server)
+
+	_, err := fetcher.Fetch(context.Background(), "s3://gpx/missing.gpx", FetchOptions{})
+	if !errors.Is(err, ErrSourceNotFound) {
+		t.Fatalf("Fetch error = %v, want ErrSourceNotFound", err)
+	}
+	if isRetryable(err) {
+		t.Error("a missing object should not be retried")
+	}
+}
+
+// TestS3SinkAsSource downloads from a bucket that the S3 store wrote with
+// compression, as happens when one run's output is the next run's source.
+func TestS3SinkAsSource(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	for _, kind := range []string{"gzip", "zstd"} {
+		sink, err := storage.NewObjectStore(config.StorageConfig{
+			Bucket:      "gpx",
+			KeyTemplate: "{file}",
+			Layout:      storage.LAYOUT_FLAT,
+			Compression: kind,
+			ObjectStore: server.Config(),
+		}, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := &models.DataRecord{FileName: kind + ".gpx"}
+		stagingPath := sink.StagingPath(record)
+		if err := os.WriteFile(stagingPath, []byte(testGPX), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Commit(context.Background(), record, stagingPath); err != nil {
+			t.Fatalf("%s: Commit: %v", kind, err)
+		}
+
+		d := newTestDownloader(t, "s3://gpx/")
+		d.cfg.URLTemplate = "{file}" + map[string]string{"gzip": ".gz", "zstd": ".zst"}[kind]
+		d.s3Fetcher = newTestS3Fetcher(t, server)
+		downloaded := &models.DataRecord{FileName: record.FileName}
+		if _, err := d.fetchFile(context.Background(), downloaded, 1); err != nil {
+			t.Fatalf("%s: fetchFile: %v", kind, err)
+		}
+		if got := readStored(t, d, downloaded); got != testGPX {
+			t.Errorf("%s: stored content = %q, want %q", kind, got, testGPX)
+		}
+	}
+}
+
+func TestS3ResumeAppendsPartialContent(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	server.Put("gpx", "track.gpx", objectstoretest.Object{Data: []byte(testGPX), ContentType: "application/gpx+xml"})
+	d := newTestDownloader(t, "s3://gpx/")
+	d.s3Fetcher = newTestS3Fetcher(t, server)
+	record := &models.DataRecord{FileName: "track.gpx"}
+	writePart(t, d, record, testGPX[:40])
+
+	statusCode, err := d.fetchFile(context.Background(), record, 1)
+	if err != nil {
+		t.Fatalf("fetchFile: %v", err)
+	}
+	if statusCode != http.StatusPartialContent {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusPartialContent)
+	}
+	if got := readStored(t, d, record); got != testGPX {
+		t.Errorf("stored content = %q, want %q", got, testGPX)
+	}
+}
@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+// ErrInvalidGPX marks a response that was received successfully but is not a
+// usable GPX document, e.g. an HTML error page or an empty track.
+var ErrInvalidGPX = errors.New("Invalid GPX payload")
+
+var allowedContentTypes = map[string]bool{
+	"application/gpx+xml":      true,
+	"application/gpx":          true,
+	"application/xml":          true,
+	"text/xml":                 true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"text/plain":               true,
+}
+
+// validateContentType rejects responses whose Content-Type can not be GPX. A
+// missing header is accepted and left to the body check.
+func validateContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: unparsable Content-Type %q", ErrInvalidGPX, contentType)
+	}
+	if !allowedContentTypes[mediaType] {
+		return fmt.Errorf("%w: unexpected Content-Type %q", ErrInvalidGPX, mediaType)
+	}
+	return nil
+}
+
+// validateGPX streams the document and checks that its root element is <gpx>
+// and that it contains at least one track, route or waypoint.
+func validateGPX(r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = true
+	sawRoot := false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			if !sawRoot {
+				return fmt.Errorf("%w: no <gpx> root element", ErrInvalidGPX)
+			}
+			return fmt.Errorf("%w: no trk, rte or wpt element", ErrInvalidGPX)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidGPX, err.Error())
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !sawRoot {
+			if element.Name.Local != "gpx" {
+				return fmt.Errorf("%w: root element is <%s>, not <gpx>", ErrInvalidGPX, element.Name.Local)
+			}
+			sawRoot = true
+			continue
+		}
+
+		switch element.Name.Local {
+		case "trk", "rte", "wpt":
+			return nil
+		}
+	}
+}
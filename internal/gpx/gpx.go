@@ -0,0 +1,56 @@
+package gpx
+
+import (
+	"time"
+)
+
+// Point is a single position from a track, route or waypoint. Elevation and
+// Time are optional in GPX; HasElevation tells a missing elevation from one
+// at sea level and Time is zero when absent.
+type Point struct {
+	Latitude     float64
+	Longitude    float64
+	Elevation    float64
+	HasElevation bool
+	Time         time.Time
+}
+
+type Waypoint struct {
+	Point
+	Name string
+}
+
+type Route struct {
+	Name   string
+	Points []Point
+}
+
+type Segment struct {
+	Points []Point
+}
+
+type Track struct {
+	Name     string
+	Segments []Segment
+}
+
+// GPX is a parsed GPX 1.0 or 1.1 document.
+type GPX struct {
+	Version   string
+	Creator   string
+	Name      string
+	Waypoints []Waypoint
+	Routes    []Route
+	Tracks    []Track
+}
+
+// PointCount returns the number of track points in all segments.
+func (g *GPX) PointCount() int {
+	count := 0
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			count += len(segment.Points)
+		}
+	}
+	return count
+}
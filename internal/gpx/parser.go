@@ -0,0 +1,320 @@
+package gpx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMalformed is returned for documents that are not well-formed XML or not
+// valid GPX. The wrapping error says what was wrong and on which line.
+var ErrMalformed = errors.New("Malformed GPX")
+
+var timeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+// xmlPoint is decoded one element at a time, so only a single point is held
+// as XML at any moment, however large the document is.
+type xmlPoint struct {
+	Lat  string  `xml:"lat,attr"`
+	Lon  string  `xml:"lon,attr"`
+	Ele  *string `xml:"ele"`
+	Time *string `xml:"time"`
+	Name string  `xml:"name"`
+}
+
+type parser struct {
+	decoder *xml.Decoder
+}
+
+// Parse streams a GPX 1.0 or 1.1 document from r into typed tracks, routes
+// and waypoints. Extensions and metadata other than the name are skipped.
+func Parse(r io.Reader) (*GPX, error) {
+	decoder := xml.NewDecoder(bufio.NewReader(r))
+	decoder.Strict = true
+	decoder.CharsetReader = charsetReader
+	p := &parser{decoder: decoder}
+
+	root, err := p.root()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &GPX{}
+	for _, attr := range root.Attr {
+		switch attr.Name.Local {
+		case "version":
+			doc.Version = attr.Value
+		case "creator":
+			doc.Creator = attr.Value
+		}
+	}
+	if doc.Version != "" && doc.Version != "1.0" && doc.Version != "1.1" {
+		return nil, p.errorf("unsupported GPX version %q", doc.Version)
+	}
+
+	err = p.children(func(element xml.StartElement) error {
+		switch element.Name.Local {
+		case "name":
+			return p.text(element, &doc.Name)
+		case "metadata":
+			// GPX 1.1 moved the document name into <metadata>.
+			return p.children(func(element xml.StartElement) error {
+				if element.Name.Local == "name" {
+					return p.text(element, &doc.Name)
+				}
+				return p.decoder.Skip()
+			})
+		case "wpt":
+			point, name, err := p.point(element)
+			if err != nil {
+				return err
+			}
+			doc.Waypoints = append(doc.Waypoints, Waypoint{Point: point, Name: name})
+			return nil
+		case "rte":
+			route, err := p.route()
+			if err != nil {
+				return err
+			}
+			doc.Routes = append(doc.Routes, route)
+			return nil
+		case "trk":
+			track, err := p.track()
+			if err != nil {
+				return err
+			}
+			doc.Tracks = append(doc.Tracks, track)
+			return nil
+		default:
+			return p.decoder.Skip()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// root reads up to the document element and checks that it is <gpx>.
+func (p *parser) root() (xml.StartElement, error) {
+	for {
+		token, err := p.decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return xml.StartElement{}, fmt.Errorf("%w: no <gpx> root element", ErrMalformed)
+		}
+		if err != nil {
+			return xml.StartElement{}, p.wrap(err)
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			if element.Name.Local != "gpx" {
+				return xml.StartElement{}, p.errorf("root element is <%s>, not <gpx>", element.Name.Local)
+			}
+			return element, nil
+		}
+	}
+}
+
+// children calls handle for each child element of the element just opened
+// and returns once it is closed. handle must consume the whole child.
+func (p *parser) children(handle func(xml.StartElement) error) error {
+	for {
+		token, err := p.decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return p.errorf("unexpected end of document")
+		}
+		if err != nil {
+			return p.wrap(err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if err := handle(token); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (p *parser) route() (Route, error) {
+	var route Route
+	err := p.children(func(element xml.StartElement) error {
+		switch element.Name.Local {
+		case "name":
+			return p.text(element, &route.Name)
+		case "rtept":
+			point, _, err := p.point(element)
+			if err != nil {
+				return err
+			}
+			route.Points = append(route.Points, point)
+			return nil
+		default:
+			return p.decoder.Skip()
+		}
+	})
+	return route, err
+}
+
+func (p *parser) track() (Track, error) {
+	var track Track
+	err := p.children(func(element xml.StartElement) error {
+		switch element.Name.Local {
+		case "name":
+			return p.text(element, &track.Name)
+		case "trkseg":
+			segment, err := p.segment()
+			if err != nil {
+				return err
+			}
+			track.Segments = append(track.Segments, segment)
+			return nil
+		default:
+			return p.decoder.Skip()
+		}
+	})
+	return track, err
+}
+
+func (p *parser) segment() (Segment, error) {
+	var segment Segment
+	err := p.children(func(element xml.StartElement) error {
+		if element.Name.Local != "trkpt" {
+			return p.decoder.Skip()
+		}
+		point, _, err := p.point(element)
+		if err != nil {
+			return err
+		}
+		segment.Points = append(segment.Points, point)
+		return nil
+	})
+	return segment, err
+}
+
+// point decodes a wpt, rtept or trkpt element and returns its name, which is
+// only meaningful for waypoints.
+func (p *parser) point(element xml.StartElement) (Point, string, error) {
+	var raw xmlPoint
+	if err := p.decoder.DecodeElement(&raw, &element); err != nil {
+		return Point{}, "", p.wrap(err)
+	}
+
+	var point Point
+	var err error
+	if point.Latitude, err = parseCoordinate(raw.Lat, 90); err != nil {
+		return Point{}, "", p.errorf("<%s> has an invalid lat %q", element.Name.Local, raw.Lat)
+	}
+	if point.Longitude, err = parseCoordinate(raw.Lon, 180); err != nil {
+		return Point{}, "", p.errorf("<%s> has an invalid lon %q", element.Name.Local, raw.Lon)
+	}
+	if raw.Ele != nil {
+		if point.Elevation, err = strconv.ParseFloat(strings.TrimSpace(*raw.Ele), 64); err != nil {
+			return Point{}, "", p.errorf("<%s> has an invalid ele %q", element.Name.Local, *raw.Ele)
+		}
+		point.HasElevation = true
+	}
+	if raw.Time != nil {
+		if point.Time, err = parseTime(*raw.Time); err != nil {
+			return Point{}, "", p.errorf("<%s> has an invalid time %q", element.Name.Local, *raw.Time)
+		}
+	}
+	return point, strings.TrimSpace(raw.Name), nil
+}
+
+func (p *parser) text(element xml.StartElement, value *string) error {
+	var text string
+	if err := p.decoder.DecodeElement(&text, &element); err != nil {
+		return p.wrap(err)
+	}
+	*value = strings.TrimSpace(text)
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line, _ := p.decoder.InputPos()
+	return fmt.Errorf("%w: line %d: %s", ErrMalformed, line, fmt.Sprintf(format, args...))
+}
+
+// wrap reports XML errors as ErrMalformed. Read errors from the underlying
+// reader are returned as they are.
+func (p *parser) wrap(err error) error {
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%w: line %d: %s", ErrMalformed, syntaxErr.Line, syntaxErr.Msg)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return p.errorf("unexpected end of document")
+	}
+	return err
+}
+
+func parseCoordinate(value string, limit float64) (float64, error) {
+	coordinate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, err
+	}
+	if coordinate < -limit || coordinate > limit {
+		return 0, fmt.Errorf("%v is out of range", coordinate)
+	}
+	return coordinate, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	var err error
+	for _, format := range timeFormats {
+		var parsed time.Time
+		if parsed, err = time.Parse(format, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// charsetReader lets the decoder read the Latin-1 documents some older
+// devices write. UTF-8 is handled by the decoder itself.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1", "us-ascii":
+		return &latin1Reader{reader: bufio.NewReader(input)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported encoding %q", ErrMalformed, charset)
+	}
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8. Each byte is its own code
+// point, so the output is at most twice as long as the input.
+type latin1Reader struct {
+	reader  *bufio.Reader
+	pending []byte
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) > 0 {
+			copied := copy(p[n:], r.pending)
+			r.pending = r.pending[copied:]
+			n += copied
+			continue
+		}
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		r.pending = []byte(string(rune(b)))
+	}
+	return n, nil
+}
@@ -0,0 +1,168 @@
+package gpx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gpx11 = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <metadata><name>Morning hike</name><time>2024-05-01T06:00:00Z</time></metadata>
+  <wpt lat="25.0330" lon="121.5654"><ele>12.5</ele><name> Trailhead </name></wpt>
+  <rte><name>Planned</name>
+    <rtept lat="25.0331" lon="121.5655"/>
+    <rtept lat="25.0332" lon="121.5656"/>
+  </rte>
+  <trk><name>Recorded</name>
+    <extensions><speed>1.2</speed></extensions>
+    <trkseg>
+      <trkpt lat="25.0330" lon="121.5654"><ele>10</ele><time>2024-05-01T06:00:00Z</time></trkpt>
+      <trkpt lat="25.0340" lon="121.5664"><ele>12</ele><time>2024-05-01T06:01:00.5Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="-33.8688" lon="151.2093"><ele>0</ele><time>2024-05-01T06:02:00+08:00</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>
+`
+
+// GPX 1.0 keeps the name on the root and has no <metadata>.
+const gpx10 = `<?xml version="1.0"?>
+<gpx version="1.0" creator="old device" xmlns="http://www.topografix.com/GPX/1/0">
+  <name>Evening ride</name>
+  <time>2010-01-01T18:00:00Z</time>
+  <trk><trkseg>
+    <trkpt lat="48.2082" lon="16.3738"><ele>171</ele><time>2010-01-01T18:00:00Z</time><speed>5</speed></trkpt>
+  </trkseg></trk>
+</gpx>
+`
+
+func mustParse(t *testing.T, document string) *GPX {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return doc
+}
+
+func TestParseGPX11(t *testing.T) {
+	doc := mustParse(t, gpx11)
+	if doc.Version != "1.1" || doc.Creator != "test" || doc.Name != "Morning hike" {
+		t.Errorf("version %q, creator %q, name %q", doc.Version, doc.Creator, doc.Name)
+	}
+
+	if len(doc.Waypoints) != 1 {
+		t.Fatalf("waypoints = %d, want 1", len(doc.Waypoints))
+	}
+	waypoint := doc.Waypoints[0]
+	if waypoint.Name != "Trailhead" || waypoint.Latitude != 25.0330 || waypoint.Elevation != 12.5 || !waypoint.HasElevation {
+		t.Errorf("waypoint = %+v", waypoint)
+	}
+
+	if len(doc.Routes) != 1 || doc.Routes[0].Name != "Planned" || len(doc.Routes[0].Points) != 2 {
+		t.Fatalf("routes = %+v, want one route with 2 points", doc.Routes)
+	}
+
+	if len(doc.Tracks) != 1 || doc.Tracks[0].Name != "Recorded" || len(doc.Tracks[0].Segments) != 2 {
+		t.Fatalf("tracks = %+v, want one track with 2 segments", doc.Tracks)
+	}
+	if got := doc.PointCount(); got != 3 {
+		t.Errorf("PointCount = %d, want 3", got)
+	}
+	second := doc.Tracks[0].Segments[0].Points[1]
+	if want := time.Date(2024, 5, 1, 6, 1, 0, 500_000_000, time.UTC); !second.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", second.Time, want)
+	}
+	last := doc.Tracks[0].Segments[1].Points[0]
+	if last.Latitude != -33.8688 || last.Longitude != 151.2093 || !last.HasElevation || last.Elevation != 0 {
+		t.Errorf("point = %+v", last)
+	}
+	if want := time.Date(2024, 4, 30, 22, 2, 0, 0, time.UTC); !last.Time.Equal(want) {
+		t.Errorf("time with offset = %v, want %v", last.Time, want)
+	}
+}
+
+func TestParseGPX10(t *testing.T) {
+	doc := mustParse(t, gpx10)
+	if doc.Version != "1.0" || doc.Name != "Evening ride" {
+		t.Errorf("version %q, name %q", doc.Version, doc.Name)
+	}
+	if got := doc.PointCount(); got != 1 {
+		t.Fatalf("PointCount = %d, want 1", got)
+	}
+	point := doc.Tracks[0].Segments[0].Points[0]
+	if point.Elevation != 171 || point.Time.IsZero() {
+		t.Errorf("point = %+v", point)
+	}
+}
+
+func TestParseWithoutElevationOrTime(t *testing.T) {
+	doc := mustParse(t, `<gpx version="1.1"><trk><trkseg>
+<trkpt lat="1" lon="2"/>
+<trkpt lat="1.001" lon="2.001"></trkpt>
+</trkseg></trk></gpx>`)
+	for _, point := range doc.Tracks[0].Segments[0].Points {
+		if point.HasElevation || point.Elevation != 0 || !point.Time.IsZero() {
+			t.Errorf("point = %+v, want no elevation and no time", point)
+		}
+	}
+
+	stats := doc.Stats(0)
+	if stats.HasElevation || stats.HasTime {
+		t.Errorf("stats report elevation %v and time %v for a file without either", stats.HasElevation, stats.HasTime)
+	}
+	if stats.Distance == 0 {
+		t.Error("distance was not computed")
+	}
+}
+
+func TestParseTimeWithoutZone(t *testing.T) {
+	doc := mustParse(t, `<gpx><wpt lat="0" lon="0"><time>2024-05-01T06:00:00</time></wpt></gpx>`)
+	if want := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC); !doc.Waypoints[0].Time.Equal(want) {
+		t.Errorf("time = %v, want %v", doc.Waypoints[0].Time, want)
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	// "Café" with é as the single Latin-1 byte 0xE9.
+	document := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<gpx version=\"1.1\"><wpt lat=\"0\" lon=\"0\"><name>Caf\xe9</name></wpt></gpx>"
+	doc := mustParse(t, document)
+	if got := doc.Waypoints[0].Name; got != "Café" {
+		t.Errorf("name = %q, want %q", got, "Café")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		// Each error names the line it was found on.
+		want string
+	}{
+		{"empty", ``, "no <gpx> root element"},
+		{"wrong root", `<html><body/></html>`, "line 1: root element is <html>, not <gpx>"},
+		{"unclosed element", "<gpx version=\"1.1\">\n<trk>\n<trkseg>\n</trk></gpx>", "line 4:"},
+		{"truncated", "<gpx version=\"1.1\">\n<trk><trkseg>\n<trkpt lat=\"1\" lon=\"2\">", "unexpected"},
+		{"unsupported version", `<gpx version="2.0"></gpx>`, `line 1: unsupported GPX version "2.0"`},
+		{"invalid lat", "<gpx>\n<wpt lat=\"north\" lon=\"2\"/></gpx>", `line 2: <wpt> has an invalid lat "north"`},
+		{"lat out of range", `<gpx><wpt lat="91" lon="2"/></gpx>`, `<wpt> has an invalid lat "91"`},
+		{"invalid lon", `<gpx><trk><trkseg><trkpt lat="1" lon="200"/></trkseg></trk></gpx>`, `<trkpt> has an invalid lon "200"`},
+		{"missing lon", `<gpx><rte><rtept lat="1"/></rte></gpx>`, `<rtept> has an invalid lon ""`},
+		{"invalid ele", `<gpx><wpt lat="1" lon="2"><ele>high</ele></wpt></gpx>`, `<wpt> has an invalid ele "high"`},
+		{"invalid time", "<gpx>\n\n<wpt lat=\"1\" lon=\"2\"><time>yesterday</time></wpt></gpx>", `line 3: <wpt> has an invalid time "yesterday"`},
+		{"unsupported encoding", `<?xml version="1.0" encoding="EBCDIC"?><gpx/>`, `unsupported encoding "EBCDIC"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.document))
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: error = %v, want ErrMalformed", tt.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %q, want it to contain %q", tt.name, err.Error(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package gpx
+
+import (
+	"container/heap"
+	"math"
+	"strings"
+)
+
+const (
+	SIMPLIFY_NONE            = "none"
+	SIMPLIFY_DOUGLAS_PEUCKER = "douglas-peucker"
+	SIMPLIFY_VISVALINGAM     = "visvalingam"
+	POLYLINE_PRECISION       = 1e5
+)
+
+// Simplify reduces points with the named algorithm, dropping only points
+// within tolerance metres of the simplified line. The first and last points
+// are always kept. Unknown algorithms and SIMPLIFY_NONE return points
+// unchanged.
+func Simplify(points []Point, algorithm string, tolerance float64) []Point {
+	switch algorithm {
+	case SIMPLIFY_DOUGLAS_PEUCKER:
+		return SimplifyDouglasPeucker(points, tolerance)
+	case SIMPLIFY_VISVALINGAM:
+		return SimplifyVisvalingam(points, tolerance)
+	default:
+		return points
+	}
+}
+
+// planar projects points onto a flat plane in metres around the first point.
+// Tracks span a few kilometres at most, where the error of an
+// equirectangular projection is far below GPS accuracy.
+func planar(points []Point) [][2]float64 {
+	projected := make([][2]float64, len(points))
+	if len(points) == 0 {
+		return projected
+	}
+	scale := math.Cos(points[0].Latitude * math.Pi / 180)
+	for idx, point := range points {
+		projected[idx] = [2]float64{
+			EARTH_RADIUS * point.Longitude * math.Pi / 180 * scale,
+			EARTH_RADIUS * point.Latitude * math.Pi / 180,
+		}
+	}
+	return projected
+}
+
+// segmentDistance returns the distance from p to the line segment a-b.
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
+
+// SimplifyDouglasPeucker keeps the point furthest from the line between the
+// ends of a stretch whenever it is more than tolerance metres away, and
+// repeats on both halves. It works with a stack so long tracks cannot
+// overflow the call stack.
+func SimplifyDouglasPeucker(points []Point, tolerance float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	projected := planar(points)
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		furthest, maxDistance := 0, 0.0
+		for idx := first + 1; idx < last; idx++ {
+			distance := segmentDistance(projected[idx], projected[first], projected[last])
+			if distance > maxDistance {
+				furthest, maxDistance = idx, distance
+			}
+		}
+		if maxDistance > tolerance {
+			keep[furthest] = true
+			stack = append(stack, [2]int{first, furthest}, [2]int{furthest, last})
+		}
+	}
+
+	simplified := make([]Point, 0, len(points))
+	for idx, point := range points {
+		if keep[idx] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// vertex is a point in the Visvalingam queue with its neighbours still in
+// the line.
+type vertex struct {
+	index      int
+	area       float64
+	previous   int
+	next       int
+	heapIndex  int
+	eliminated bool
+}
+
+type vertexQueue []*vertex
+
+func (q vertexQueue) Len() int           { return len(q) }
+func (q vertexQueue) Less(i, j int) bool { return q[i].area < q[j].area }
+func (q vertexQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].heapIndex = i
+	q[j].heapIndex = j
+}
+
+func (q *vertexQueue) Push(x any) {
+	v := x.(*vertex)
+	v.heapIndex = len(*q)
+	*q = append(*q, v)
+}
+
+func (q *vertexQueue) Pop() any {
+	old := *q
+	v := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return v
+}
+
+func triangleArea(a, b, c [2]float64) float64 {
+	return math.Abs((b[0]-a[0])*(c[1]-a[1])-(c[0]-a[0])*(b[1]-a[1])) / 2
+}
+
+// SimplifyVisvalingam visits points in order of the area of the triangle they
+// form with their neighbours, smallest first, and drops each one that lies
+// within tolerance metres of the line between its neighbours. After a drop
+// the neighbours' triangles are recomputed. Ordering by area removes the
+// least significant points first, while the distance check gives tolerance
+// the same meaning as for Douglas-Peucker.
+func SimplifyVisvalingam(points []Point, tolerance float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	projected := planar(points)
+
+	vertices := make([]*vertex, len(points))
+	for idx := range points {
+		vertices[idx] = &vertex{index: idx, previous: idx - 1, next: idx + 1}
+	}
+	queue := make(vertexQueue, 0, len(points)-2)
+	for idx := 1; idx < len(points)-1; idx++ {
+		vertices[idx].area = triangleArea(projected[idx-1], projected[idx], projected[idx+1])
+		heap.Push(&queue, vertices[idx])
+	}
+
+	// Points that are kept leave the queue, so their area is only
+	// recomputed while they are still in it.
+	update := func(v *vertex) {
+		if v.index == 0 || v.index == len(points)-1 || v.heapIndex < 0 {
+			return
+		}
+		v.area = triangleArea(projected[v.previous], projected[v.index], projected[v.next])
+		heap.Fix(&queue, v.heapIndex)
+	}
+
+	for queue.Len() > 0 {
+		v := heap.Pop(&queue).(*vertex)
+		v.heapIndex = -1
+		if segmentDistance(projected[v.index], projected[v.previous], projected[v.next]) > tolerance {
+			continue
+		}
+		v.eliminated = true
+		previous, next := vertices[v.previous], vertices[v.next]
+		previous.next = next.index
+		next.previous = previous.index
+		update(previous)
+		update(next)
+	}
+
+	simplified := make([]Point, 0, len(points))
+	for idx, point := range points {
+		if !vertices[idx].eliminated {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// EncodePolyline encodes points in the Google encoded polyline format with
+// five decimal places, as read by most map libraries.
+func EncodePolyline(points []Point) string {
+	var builder strings.Builder
+	var previousLat, previousLon int64
+	for _, point := range points {
+		lat := int64(math.Round(point.Latitude * POLYLINE_PRECISION))
+		lon := int64(math.Round(point.Longitude * POLYLINE_PRECISION))
+		encodeValue(&builder, lat-previousLat)
+		encodeValue(&builder, lon-previousLon)
+		previousLat, previousLon = lat, lon
+	}
+	return builder.String()
+}
+
+// SimplifiedTrack simplifies every segment of every track on its own and
+// joins the results, so segment ends are never dropped.
+func (g *GPX) SimplifiedTrack(algorithm string, tolerance float64) []Point {
+	var simplified []Point
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			simplified = append(simplified, Simplify(segment.Points, algorithm, tolerance)...)
+		}
+	}
+	return simplified
+}
+
+// encodeValue writes value zig-zag encoded in 5-bit chunks, lowest first.
+// Every chunk but the last has 0x20 set, and all are offset by 63 to make
+// them printable.
+func encodeValue(builder *strings.Builder, value int64) {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		builder.WriteByte(byte((0x20 | (shifted & 0x1f)) + 63))
+		shifted >>= 5
+	}
+	builder.WriteByte(byte(shifted + 63))
+}
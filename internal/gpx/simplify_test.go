@@ -0,0 +1,140 @@
+package gpx
+
+import (
+	"math"
+	"testing"
+)
+
+// metresPerDegree converts the synthetic tracks, laid out in metres around
+// the equator, to degrees.
+var metresPerDegree = EARTH_RADIUS * math.Pi / 180
+
+func at(x, y float64) Point {
+	return Point{Latitude: y / metresPerDegree, Longitude: x / metresPerDegree}
+}
+
+// straightLine returns count points 10 m apart heading east.
+func straightLine(count int) []Point {
+	points := make([]Point, count)
+	for idx := range points {
+		points[idx] = at(float64(idx)*10, 0)
+	}
+	return points
+}
+
+// zigZag returns count points 10 m apart heading east that alternate
+// amplitude metres north and south of the line, starting and ending on it.
+func zigZag(count int, amplitude float64) []Point {
+	points := make([]Point, count)
+	for idx := range points {
+		offset := amplitude
+		if idx%2 == 1 {
+			offset = -amplitude
+		}
+		if idx == 0 || idx == count-1 {
+			offset = 0
+		}
+		points[idx] = at(float64(idx)*10, offset)
+	}
+	return points
+}
+
+var simplifiers = map[string]func([]Point, float64) []Point{
+	SIMPLIFY_DOUGLAS_PEUCKER: SimplifyDouglasPeucker,
+	SIMPLIFY_VISVALINGAM:     SimplifyVisvalingam,
+}
+
+func samePoint(a, b Point) bool {
+	return a.Latitude == b.Latitude && a.Longitude == b.Longitude
+}
+
+func TestSimplifyStraightLine(t *testing.T) {
+	line := straightLine(100)
+	for name, simplify := range simplifiers {
+		simplified := simplify(line, 1)
+		if len(simplified) != 2 {
+			t.Errorf("%s: kept %d points of a straight line, want 2", name, len(simplified))
+			continue
+		}
+		if !samePoint(simplified[0], line[0]) || !samePoint(simplified[1], line[len(line)-1]) {
+			t.Errorf("%s: did not keep the ends of the line", name)
+		}
+	}
+}
+
+func TestSimplifyZigZagAboveTolerance(t *testing.T) {
+	// Every corner is 20 m off the line, far beyond the 5 m tolerance.
+	track := zigZag(21, 20)
+	for name, simplify := range simplifiers {
+		if simplified := simplify(track, 5); len(simplified) != len(track) {
+			t.Errorf("%s: kept %d of %d points, want all of them", name, len(simplified), len(track))
+		}
+	}
+}
+
+func TestSimplifyZigZagBelowTolerance(t *testing.T) {
+	// Corners 0.5 m off the line are GPS noise at a 5 m tolerance.
+	track := zigZag(21, 0.5)
+	for name, simplify := range simplifiers {
+		simplified := simplify(track, 5)
+		if len(simplified) != 2 {
+			t.Errorf("%s: kept %d of %d points, want 2", name, len(simplified), len(track))
+			continue
+		}
+		if !samePoint(simplified[0], track[0]) || !samePoint(simplified[1], track[len(track)-1]) {
+			t.Errorf("%s: did not keep the ends of the track", name)
+		}
+	}
+}
+
+func TestSimplifyKeepsSingleDetour(t *testing.T) {
+	// A straight track with one 50 m detour in the middle.
+	track := straightLine(41)
+	track[20] = at(200, 50)
+	for name, simplify := range simplifiers {
+		simplified := simplify(track, 5)
+		found := false
+		for _, point := range simplified {
+			found = found || samePoint(point, track[20])
+		}
+		if !found {
+			t.Errorf("%s: dropped the detour", name)
+		}
+		if len(simplified) >= len(track) {
+			t.Errorf("%s: kept all %d points of the straight stretches", name, len(simplified))
+		}
+	}
+}
+
+func TestSimplifyShortInput(t *testing.T) {
+	for name, simplify := range simplifiers {
+		for count := 0; count < 3; count++ {
+			track := zigZag(count, 100)
+			if simplified := simplify(track, 1000); len(simplified) != count {
+				t.Errorf("%s: %d points simplified to %d, want them unchanged", name, count, len(simplified))
+			}
+		}
+	}
+}
+
+func TestSimplifyNone(t *testing.T) {
+	track := zigZag(21, 0.5)
+	if simplified := Simplify(track, SIMPLIFY_NONE, 5); len(simplified) != len(track) {
+		t.Errorf("kept %d of %d points, want all of them", len(simplified), len(track))
+	}
+}
+
+func TestEncodePolyline(t *testing.T) {
+	// The reference example of the encoded polyline format.
+	points := []Point{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+	}
+	if got, want := EncodePolyline(points), "_p~iF~ps|U_ulLnnqC_mqNvxq`@"; got != want {
+		t.Errorf("EncodePolyline = %q, want %q", got, want)
+	}
+	if got := EncodePolyline(nil); got != "" {
+		t.Errorf("EncodePolyline(nil) = %q, want an empty string", got)
+	}
+}
@@ -0,0 +1,155 @@
+package gpx
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// EARTH_RADIUS is the mean radius of the earth in metres.
+	EARTH_RADIUS = 6371008.8
+)
+
+// Stats are the metrics of a track as computed from its points. Distances
+// and elevations are in metres.
+type Stats struct {
+	Distance      float64
+	Duration      time.Duration
+	Ascent        float64
+	Descent       float64
+	ElevationDiff float64
+	// HasTime and HasElevation are false when no point had a time or an
+	// elevation, in which case the matching metrics are zero.
+	HasTime      bool
+	HasElevation bool
+}
+
+// Haversine returns the great-circle distance between a and b in metres.
+func Haversine(a, b Point) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Stats computes the metrics of all tracks in the document. Distance is not
+// counted across the gap between two segments.
+//
+// GPS elevation jitters by a few metres even when standing still, so ascent
+// and descent only count a climb or drop once it exceeds elevationThreshold
+// metres from the last counted elevation.
+func (g *GPX) Stats(elevationThreshold float64) Stats {
+	var stats Stats
+	var first, last time.Time
+	var reference, lowest, highest float64
+
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for idx, point := range segment.Points {
+				if idx > 0 {
+					stats.Distance += Haversine(segment.Points[idx-1], point)
+				}
+
+				if !point.Time.IsZero() {
+					if first.IsZero() || point.Time.Before(first) {
+						first = point.Time
+					}
+					if point.Time.After(last) {
+						last = point.Time
+					}
+				}
+
+				if !point.HasElevation {
+					continue
+				}
+				if !stats.HasElevation {
+					stats.HasElevation = true
+					reference, lowest, highest = point.Elevation, point.Elevation, point.Elevation
+					continue
+				}
+				lowest = math.Min(lowest, point.Elevation)
+				highest = math.Max(highest, point.Elevation)
+				switch change := point.Elevation - reference; {
+				case change >= elevationThreshold:
+					stats.Ascent += change
+					reference = point.Elevation
+				case -change >= elevationThreshold:
+					stats.Descent -= change
+					reference = point.Elevation
+				}
+			}
+		}
+	}
+
+	if !first.IsZero() {
+		stats.HasTime = true
+		stats.Duration = last.Sub(first)
+	}
+	stats.ElevationDiff = highest - lowest
+	return stats
+}
+
+// Movement splits the time of a track into moving and stopped time. Speeds
+// are in metres per second.
+type Movement struct {
+	MovingTime     time.Duration
+	StoppedTime    time.Duration
+	MovingDistance float64
+	AverageSpeed   float64
+	MaxSpeed       float64
+}
+
+// Pace returns the moving time per kilometre, or zero when the track did
+// not move.
+func (m Movement) Pace() time.Duration {
+	if m.MovingDistance == 0 {
+		return 0
+	}
+	return time.Duration(float64(m.MovingTime) * 1000 / m.MovingDistance)
+}
+
+// Movement compares the speed between every two consecutive timed points of
+// a segment with speedThreshold, in metres per second. Slower stretches count
+// as stopped. Points without a time are skipped.
+func (g *GPX) Movement(speedThreshold float64) Movement {
+	var movement Movement
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			var previous *Point
+			for idx := range segment.Points {
+				point := &segment.Points[idx]
+				if point.Time.IsZero() {
+					continue
+				}
+				if previous == nil {
+					previous = point
+					continue
+				}
+
+				elapsed := point.Time.Sub(previous.Time)
+				if elapsed <= 0 {
+					continue
+				}
+				distance := Haversine(*previous, *point)
+				previous = point
+
+				speed := distance / elapsed.Seconds()
+				if speed < speedThreshold {
+					movement.StoppedTime += elapsed
+					continue
+				}
+				movement.MovingTime += elapsed
+				movement.MovingDistance += distance
+				movement.MaxSpeed = math.Max(movement.MaxSpeed, speed)
+			}
+		}
+	}
+
+	if movement.MovingTime > 0 {
+		movement.AverageSpeed = movement.MovingDistance / movement.MovingTime.Seconds()
+	}
+	return movement
+}
@@ -0,0 +1,124 @@
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+const (
+	MAX_LINE_SIZE = 1024 * 1024
+)
+
+// Log is an append-only JSON-lines file of entries keyed by name. The last
+// line for a key wins, so a crash can at worst lose the line being written.
+// It is compacted to one line per key each time it is opened.
+type Log[E any] struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open loads the entries of the log at filePath, rewrites it with one line
+// per key and opens it for appending. key returns the key of an entry, or an
+// empty string for entries that should be dropped.
+func Open[E any](filePath string, key func(E) string) (*Log[E], map[string]E, error) {
+	entries, err := read(filePath, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return nil, nil, err
+	}
+	if err := compact(filePath, entries); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Log[E]{file: file}, entries, nil
+}
+
+func read[E any](filePath string, key func(E) string) (map[string]E, error) {
+	entries := make(map[string]E)
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), MAX_LINE_SIZE)
+	for scanner.Scan() {
+		var entry E
+		// A torn final line from a killed process is simply ignored.
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if name := key(entry); name != "" {
+			entries[name] = entry
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// compact replaces the file with one line per entry, through a temporary
+// file so a crash leaves either the old or the new log.
+func compact[E any](filePath string, entries map[string]E) error {
+	tmpPath := filePath + ".tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmpFile)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmpFile.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// Append writes entry as a new line.
+func (l *Log[E]) Append(entry E) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(data, '\n'))
+	return err
+}
+
+func (l *Log[E]) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,77 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+func testKey(entry testEntry) string {
+	return entry.Name
+}
+
+func TestOpenCompactsAndAppends(t *testing.T) {
+	// The directory does not exist yet.
+	filePath := filepath.Join(t.TempDir(), "state", "log.jsonl")
+	log, entries, err := Open(filePath, testKey)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries of a new log = %v, want none", entries)
+	}
+	for _, entry := range []testEntry{{"a", 1}, {"b", 1}, {"a", 2}} {
+		if err := log.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A process killed mid-write leaves a torn final line.
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"name":"b","val`)
+	file.Close()
+
+	log, entries, err = Open(filePath, testKey)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer log.Close()
+	if len(entries) != 2 || entries["a"].Value != 2 || entries["b"].Value != 1 {
+		t.Errorf("entries = %v, want the last line for each name", entries)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("compacted log has %d lines, want 2:\n%s", lines, data)
+	}
+}
+
+func TestOpenDropsEntriesWithoutKey(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "log.jsonl")
+	if err := os.WriteFile(filePath, []byte("{\"value\":1}\nnot json\n{\"name\":\"a\",\"value\":3}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log, entries, err := Open(filePath, testKey)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+	if len(entries) != 1 || entries["a"].Value != 3 {
+		t.Errorf("entries = %v, want only a", entries)
+	}
+}
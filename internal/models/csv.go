@@ -11,6 +11,13 @@ type DataRecord struct {
 	ElevationDiff float32 `csv:"elevation_diff"`
 	Trails        string  `csv:"trails"`
 	RecordedAt    string  `csv:"recorded_at"`
+
+	// Filled in by the downloader once the GPX file is on disk so ingestion
+	// does not have to hash it again. Duplicate is set when the content was
+	// already stored for another record.
+	SHA512Sum string `csv:"-"`
+	Size      int64  `csv:"-"`
+	Duplicate bool   `csv:"-"`
 }
 
 type CSVFile struct {
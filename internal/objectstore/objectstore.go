@@ -0,0 +1,63 @@
+package objectstore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// New creates a client for an S3-compatible object store such as MinIO.
+// Requests use path-style bucket addressing, which every S3-compatible server
+// understands.
+func New(cfg config.ObjectStoreConfig) (*minio.Client, error) {
+	return minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+}
+
+// ParseURL splits an s3://bucket/key URL into its bucket and object key.
+func ParseURL(rawURL string) (string, string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Scheme != "s3" {
+		return "", "", fmt.Errorf("%q is not an s3:// URL", rawURL)
+	}
+
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Host == "" || key == "" {
+		return "", "", fmt.Errorf("%q must name both a bucket and a key", rawURL)
+	}
+	return parsed.Host, key, nil
+}
+
+// IsNotFound reports whether err means the bucket or object does not exist.
+func IsNotFound(err error) bool {
+	var errResp minio.ErrorResponse
+	if !errors.As(err, &errResp) {
+		return false
+	}
+	return errResp.StatusCode == http.StatusNotFound ||
+		errResp.Code == "NoSuchKey" ||
+		errResp.Code == "NoSuchBucket"
+}
+
+// StatusCode returns the HTTP status of an object store error, or 0 when err
+// did not come from the server.
+func StatusCode(err error) int {
+	var errResp minio.ErrorResponse
+	if !errors.As(err, &errResp) {
+		return 0
+	}
+	return errResp.StatusCode
+}
@@ -0,0 +1,201 @@
+// Package objectstoretest provides an in-process fake of the parts of the S3
+// API the object store clients use, for tests that should not need MinIO.
+package objectstoretest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+)
+
+const (
+	REGION = "us-east-1"
+)
+
+// Object is a stored object with the headers it was uploaded with.
+type Object struct {
+	Data            []byte
+	ContentType     string
+	ContentEncoding string
+	ETag            string
+	ModTime         time.Time
+}
+
+// Server serves path-style bucket/key requests from memory. Signatures are
+// not checked and every bucket exists.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]Object
+	ranges  []string
+}
+
+// NewServer starts a fake S3 server that is closed when the test ends.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	s := &Server{objects: map[string]Object{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Config returns an object store config pointing at the server.
+func (s *Server) Config() config.ObjectStoreConfig {
+	return config.ObjectStoreConfig{
+		Endpoint:  strings.TrimPrefix(s.URL, "http://"),
+		Region:    REGION,
+		AccessKey: "test",
+		SecretKey: "testsecret",
+	}
+}
+
+// Put stores an object directly, bypassing the API.
+func (s *Server) Put(bucket, key string, object Object) {
+	sum := md5.Sum(object.Data)
+	object.ETag = `"` + hex.EncodeToString(sum[:]) + `"`
+	if object.ModTime.IsZero() {
+		object.ModTime = time.Now().UTC().Truncate(time.Second)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[bucket+"/"+key] = object
+}
+
+// Get returns a stored object.
+func (s *Server) Get(bucket, key string) (Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, found := s.objects[bucket+"/"+key]
+	return object, found
+}
+
+// Ranges returns the Range header of every GET request so far, empty for
+// requests without one.
+func (s *Server) Ranges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		if _, ok := r.URL.Query()["location"]; ok {
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">%s</LocationConstraint>`, REGION)
+			return
+		}
+		http.Error(w, "bucket operations are not supported", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, bucket, key)
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, bucket, key)
+	default:
+		http.Error(w, "method not supported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body = &chunkedReader{reader: bufio.NewReader(r.Body)}
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Put(bucket, key, Object{
+		Data:            data,
+		ContentType:     r.Header.Get("Content-Type"),
+		ContentEncoding: r.Header.Get("Content-Encoding"),
+	})
+	object, _ := s.Get(bucket, key)
+	w.Header().Set("ETag", object.ETag)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if r.Method == http.MethodGet {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+	}
+
+	object, found := s.Get(bucket, key)
+	if !found {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>%s</Key><BucketName>%s</BucketName><Resource>%s</Resource><RequestId>1</RequestId><HostId>1</HostId></Error>`, key, bucket, r.URL.Path)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", object.ETag)
+	if object.ContentType != "" {
+		w.Header().Set("Content-Type", object.ContentType)
+	}
+	if object.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", object.ContentEncoding)
+	}
+	http.ServeContent(w, r, key, object.ModTime, bytes.NewReader(object.Data))
+}
+
+// chunkedReader decodes the aws-chunked bodies of streaming signed uploads:
+// "<hex size>;chunk-signature=...\r\n<data>\r\n", ending with a zero size
+// chunk.
+type chunkedReader struct {
+	reader    *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid chunk size %q: %w", sizeField, err)
+		}
+		if size == 0 {
+			c.done = true
+			continue
+		}
+		c.remaining = size
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		// Every chunk's data is followed by a CRLF.
+		_, err = c.reader.Discard(2)
+	}
+	return n, err
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -49,10 +50,20 @@ func errorOccured(fileName string, err error) error {
 	return fmt.Errorf("Error occurred while attempting to parse %s. Error: %s", fileName, err.Error())
 }
 
-func parseCSVFile(filePath string, resChan chan ParserResult, log logger.Logger, wg *sync.WaitGroup) {
+func parseCSVFile(ctx context.Context, filePath string, resChan chan ParserResult, log logger.Logger, wg *sync.WaitGroup) {
 	var content models.CSVFile
 	defer wg.Done()
 
+	if err := ctx.Err(); err != nil {
+		resChan <- ParserResult{
+			File: models.CSVFile{
+				FileName: filePath,
+			},
+			Error: errorOccured(filePath, err),
+		}
+		return
+	}
+
 	log.Info().Msgf("Opening %s", filePath)
 	file, err := os.OpenFile(filePath, os.O_RDONLY, os.ModePerm)
 	if err != nil {
@@ -99,7 +110,7 @@ func parseCSVFile(filePath string, resChan chan ParserResult, log logger.Logger,
 	return
 }
 
-func StartParser(sourcePath string, log logger.Logger) ([]models.CSVFile, []error) {
+func StartParser(ctx context.Context, sourcePath string, log logger.Logger) ([]models.CSVFile, []error) {
 	var csvFiles []models.CSVFile
 	var errors []error
 
@@ -119,7 +130,7 @@ func StartParser(sourcePath string, log logger.Logger) ([]models.CSVFile, []erro
 
 	for _, filePath := range files {
 		wg.Add(1)
-		go parseCSVFile(filePath, resChan, log, &wg)
+		go parseCSVFile(ctx, filePath, resChan, log, &wg)
 	}
 
 	go func() {
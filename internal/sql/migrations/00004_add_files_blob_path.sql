@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Files ADD COLUMN IF NOT EXISTS BlobPath TEXT;
+
+CREATE INDEX IF NOT EXISTS files_blobpath ON Files(BlobPath);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS files_blobpath;
+ALTER TABLE Files DROP COLUMN IF EXISTS BlobPath;
+-- +goose StatementEnd
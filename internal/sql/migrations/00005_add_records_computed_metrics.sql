@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Metrics recomputed from the GPX file, in the same units as the CSV values
+-- next to them. Mismatched is set when any of them is off by more than the
+-- configured tolerance; MismatchedMetrics names which ones.
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS ComputedDuration FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS ComputedDistance FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS ComputedAscent FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS ComputedDescent FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS ComputedElevationDiff FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS Mismatched BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS MismatchedMetrics TEXT;
+
+CREATE INDEX IF NOT EXISTS records_mismatched_idx ON Records(Mismatched);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS records_mismatched_idx;
+ALTER TABLE Records DROP COLUMN IF EXISTS MismatchedMetrics;
+ALTER TABLE Records DROP COLUMN IF EXISTS Mismatched;
+ALTER TABLE Records DROP COLUMN IF EXISTS ComputedElevationDiff;
+ALTER TABLE Records DROP COLUMN IF EXISTS ComputedDescent;
+ALTER TABLE Records DROP COLUMN IF EXISTS ComputedAscent;
+ALTER TABLE Records DROP COLUMN IF EXISTS ComputedDistance;
+ALTER TABLE Records DROP COLUMN IF EXISTS ComputedDuration;
+-- +goose StatementEnd
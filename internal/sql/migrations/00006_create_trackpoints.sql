@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS TrackPoints(
+    RecordId TEXT NOT NULL,
+    SegmentIndex INT NOT NULL,
+    Sequence INT NOT NULL,
+    Latitude FLOAT8 NOT NULL,
+    Longitude FLOAT8 NOT NULL,
+    Elevation FLOAT8,
+    RecordedAt TIMESTAMPTZ,
+    PRIMARY KEY (RecordId, SegmentIndex, Sequence)
+);
+
+CREATE INDEX IF NOT EXISTS trackpoints_recordedat_idx ON TrackPoints(RecordedAt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE TrackPoints;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Computed from the track point timestamps. Times are in hours like
+-- Duration, speeds in km/h and pace in minutes per km of moving.
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS MovingTime FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS StoppedTime FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS AverageMovingSpeed FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS MaxMovingSpeed FLOAT8;
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS Pace FLOAT8;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Records DROP COLUMN IF EXISTS Pace;
+ALTER TABLE Records DROP COLUMN IF EXISTS MaxMovingSpeed;
+ALTER TABLE Records DROP COLUMN IF EXISTS AverageMovingSpeed;
+ALTER TABLE Records DROP COLUMN IF EXISTS StoppedTime;
+ALTER TABLE Records DROP COLUMN IF EXISTS MovingTime;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The simplified track in the Google encoded polyline format, for map display.
+ALTER TABLE Records ADD COLUMN IF NOT EXISTS SimplifiedPolyline TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Records DROP COLUMN IF EXISTS SimplifiedPolyline;
+-- +goose StatementEnd
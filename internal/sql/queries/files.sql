@@ -4,13 +4,16 @@ SELECT * FROM Files WHERE Id = $1 LIMIT 1;
 -- name: GetFileByName :one
 SELECT * FROM Files WHERE FileName = $1 LIMIT 1;
 
+-- name: ListFiles :many
+SELECT * FROM Files;
+
 -- name: InsertFile :one
 INSERT INTO Files (
-    Id, FileName, SHA512Sum
-) VALUES ( $1, $2, $3 ) RETURNING *;
+    Id, FileName, SHA512Sum, BlobPath
+) VALUES ( $1, $2, $3, $4 ) RETURNING *;
 
 -- name: BulkInsertFiles :copyfrom
-INSERT INTO Files ( Id, FileName, SHA512Sum ) VALUES( $1, $2, $3 );
+INSERT INTO Files ( Id, FileName, SHA512Sum, BlobPath ) VALUES( $1, $2, $3, $4 );
 
 -- name: DeleteFileById :exec
 DELETE FROM Files WHERE Id = $1;
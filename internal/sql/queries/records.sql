@@ -15,16 +15,26 @@ SELECT * FROM Records WHERE UserId = $1 AND Trails = $2;
 
 -- name: InsertRecord :one
 INSERT INTO Records (
-    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData
+    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData,
+    ComputedDuration, ComputedDistance, ComputedAscent, ComputedDescent, ComputedElevationDiff,
+    Mismatched, MismatchedMetrics,
+    MovingTime, StoppedTime, AverageMovingSpeed, MaxMovingSpeed, Pace,
+    SimplifiedPolyline
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+    $18, $19, $20, $21, $22, $23
 ) RETURNING *;
 
 -- name: BulkInsertRecord :copyfrom
 INSERT INTO Records (
-    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData
+    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData,
+    ComputedDuration, ComputedDistance, ComputedAscent, ComputedDescent, ComputedElevationDiff,
+    Mismatched, MismatchedMetrics,
+    MovingTime, StoppedTime, AverageMovingSpeed, MaxMovingSpeed, Pace,
+    SimplifiedPolyline
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+    $18, $19, $20, $21, $22, $23
 );
 
 -- name: DeleteRecordById :exec
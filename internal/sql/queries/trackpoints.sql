@@ -0,0 +1,15 @@
+-- name: GetTrackPointsByRecordId :many
+SELECT * FROM TrackPoints WHERE RecordId = $1 ORDER BY SegmentIndex, Sequence;
+
+-- name: BulkInsertTrackPoints :copyfrom
+INSERT INTO TrackPoints (
+    RecordId, SegmentIndex, Sequence, Latitude, Longitude, Elevation, RecordedAt
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: DeleteTrackPointsByRecordId :exec
+DELETE FROM TrackPoints WHERE RecordId = $1;
+
+-- name: DropTrackPoints :exec
+DELETE FROM TrackPoints;
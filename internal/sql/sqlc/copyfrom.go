@@ -32,6 +32,7 @@ func (r iteratorForBulkInsertFiles) Values() ([]interface{}, error) {
 		r.rows[0].ID,
 		r.rows[0].Filename,
 		r.rows[0].Sha512sum,
+		r.rows[0].Blobpath,
 	}, nil
 }
 
@@ -40,7 +41,7 @@ func (r iteratorForBulkInsertFiles) Err() error {
 }
 
 func (q *Queries) BulkInsertFiles(ctx context.Context, arg []BulkInsertFilesParams) (int64, error) {
-	return q.db.CopyFrom(ctx, []string{"files"}, []string{"id", "filename", "sha512sum"}, &iteratorForBulkInsertFiles{rows: arg})
+	return q.db.CopyFrom(ctx, []string{"files"}, []string{"id", "filename", "sha512sum", "blobpath"}, &iteratorForBulkInsertFiles{rows: arg})
 }
 
 // iteratorForBulkInsertRecord implements pgx.CopyFromSource.
@@ -73,6 +74,19 @@ func (r iteratorForBulkInsertRecord) Values() ([]interface{}, error) {
 		r.rows[0].Elevationdiff,
 		r.rows[0].Trails,
 		r.rows[0].Rawdata,
+		r.rows[0].Computedduration,
+		r.rows[0].Computeddistance,
+		r.rows[0].Computedascent,
+		r.rows[0].Computeddescent,
+		r.rows[0].Computedelevationdiff,
+		r.rows[0].Mismatched,
+		r.rows[0].Mismatchedmetrics,
+		r.rows[0].Movingtime,
+		r.rows[0].Stoppedtime,
+		r.rows[0].Averagemovingspeed,
+		r.rows[0].Maxmovingspeed,
+		r.rows[0].Pace,
+		r.rows[0].Simplifiedpolyline,
 	}, nil
 }
 
@@ -81,5 +95,43 @@ func (r iteratorForBulkInsertRecord) Err() error {
 }
 
 func (q *Queries) BulkInsertRecord(ctx context.Context, arg []BulkInsertRecordParams) (int64, error) {
-	return q.db.CopyFrom(ctx, []string{"records"}, []string{"id", "userid", "fileid", "duration", "distance", "ascent", "descent", "elevationdiff", "trails", "rawdata"}, &iteratorForBulkInsertRecord{rows: arg})
+	return q.db.CopyFrom(ctx, []string{"records"}, []string{"id", "userid", "fileid", "duration", "distance", "ascent", "descent", "elevationdiff", "trails", "rawdata", "computedduration", "computeddistance", "computedascent", "computeddescent", "computedelevationdiff", "mismatched", "mismatchedmetrics", "movingtime", "stoppedtime", "averagemovingspeed", "maxmovingspeed", "pace", "simplifiedpolyline"}, &iteratorForBulkInsertRecord{rows: arg})
+}
+
+// iteratorForBulkInsertTrackPoints implements pgx.CopyFromSource.
+type iteratorForBulkInsertTrackPoints struct {
+	rows                 []BulkInsertTrackPointsParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForBulkInsertTrackPoints) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForBulkInsertTrackPoints) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].Recordid,
+		r.rows[0].Segmentindex,
+		r.rows[0].Sequence,
+		r.rows[0].Latitude,
+		r.rows[0].Longitude,
+		r.rows[0].Elevation,
+		r.rows[0].Recordedat,
+	}, nil
+}
+
+func (r iteratorForBulkInsertTrackPoints) Err() error {
+	return nil
+}
+
+func (q *Queries) BulkInsertTrackPoints(ctx context.Context, arg []BulkInsertTrackPointsParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"trackpoints"}, []string{"recordid", "segmentindex", "sequence", "latitude", "longitude", "elevation", "recordedat"}, &iteratorForBulkInsertTrackPoints{rows: arg})
 }
@@ -7,12 +7,15 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type BulkInsertFilesParams struct {
-	ID        string `json:"id"`
-	Filename  string `json:"filename"`
-	Sha512sum string `json:"sha512sum"`
+	ID        string      `json:"id"`
+	Filename  string      `json:"filename"`
+	Sha512sum string      `json:"sha512sum"`
+	Blobpath  pgtype.Text `json:"blobpath"`
 }
 
 const deleteFileById = `-- name: DeleteFileById :exec
@@ -43,42 +46,92 @@ func (q *Queries) DropFiles(ctx context.Context) error {
 }
 
 const getFileById = `-- name: GetFileById :one
-SELECT id, filename, sha512sum FROM Files WHERE Id = $1 LIMIT 1
+SELECT id, filename, sha512sum, blobpath FROM Files WHERE Id = $1 LIMIT 1
 `
 
 func (q *Queries) GetFileById(ctx context.Context, id string) (File, error) {
 	row := q.db.QueryRow(ctx, getFileById, id)
 	var i File
-	err := row.Scan(&i.ID, &i.Filename, &i.Sha512sum)
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.Sha512sum,
+		&i.Blobpath,
+	)
 	return i, err
 }
 
 const getFileByName = `-- name: GetFileByName :one
-SELECT id, filename, sha512sum FROM Files WHERE FileName = $1 LIMIT 1
+SELECT id, filename, sha512sum, blobpath FROM Files WHERE FileName = $1 LIMIT 1
 `
 
 func (q *Queries) GetFileByName(ctx context.Context, filename string) (File, error) {
 	row := q.db.QueryRow(ctx, getFileByName, filename)
 	var i File
-	err := row.Scan(&i.ID, &i.Filename, &i.Sha512sum)
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.Sha512sum,
+		&i.Blobpath,
+	)
 	return i, err
 }
 
 const insertFile = `-- name: InsertFile :one
 INSERT INTO Files (
-    Id, FileName, SHA512Sum
-) VALUES ( $1, $2, $3 ) RETURNING id, filename, sha512sum
+    Id, FileName, SHA512Sum, BlobPath
+) VALUES ( $1, $2, $3, $4 ) RETURNING id, filename, sha512sum, blobpath
 `
 
 type InsertFileParams struct {
-	ID        string `json:"id"`
-	Filename  string `json:"filename"`
-	Sha512sum string `json:"sha512sum"`
+	ID        string      `json:"id"`
+	Filename  string      `json:"filename"`
+	Sha512sum string      `json:"sha512sum"`
+	Blobpath  pgtype.Text `json:"blobpath"`
 }
 
 func (q *Queries) InsertFile(ctx context.Context, arg InsertFileParams) (File, error) {
-	row := q.db.QueryRow(ctx, insertFile, arg.ID, arg.Filename, arg.Sha512sum)
+	row := q.db.QueryRow(ctx, insertFile,
+		arg.ID,
+		arg.Filename,
+		arg.Sha512sum,
+		arg.Blobpath,
+	)
 	var i File
-	err := row.Scan(&i.ID, &i.Filename, &i.Sha512sum)
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.Sha512sum,
+		&i.Blobpath,
+	)
 	return i, err
 }
+
+const listFiles = `-- name: ListFiles :many
+SELECT id, filename, sha512sum, blobpath FROM Files
+`
+
+func (q *Queries) ListFiles(ctx context.Context) ([]File, error) {
+	rows, err := q.db.Query(ctx, listFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []File{}
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.Filename,
+			&i.Sha512sum,
+			&i.Blobpath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
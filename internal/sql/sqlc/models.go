@@ -9,22 +9,46 @@ import (
 )
 
 type File struct {
-	ID        string `json:"id"`
-	Filename  string `json:"filename"`
-	Sha512sum string `json:"sha512sum"`
+	ID        string      `json:"id"`
+	Filename  string      `json:"filename"`
+	Sha512sum string      `json:"sha512sum"`
+	Blobpath  pgtype.Text `json:"blobpath"`
 }
 
 type Record struct {
-	ID            string        `json:"id"`
-	Userid        string        `json:"userid"`
-	Fileid        string        `json:"fileid"`
-	Duration      pgtype.Float8 `json:"duration"`
-	Distance      pgtype.Float8 `json:"distance"`
-	Ascent        pgtype.Float8 `json:"ascent"`
-	Descent       pgtype.Float8 `json:"descent"`
-	Elevationdiff pgtype.Float8 `json:"elevationdiff"`
-	Trails        pgtype.Text   `json:"trails"`
-	Rawdata       pgtype.Text   `json:"rawdata"`
+	ID                    string        `json:"id"`
+	Userid                string        `json:"userid"`
+	Fileid                string        `json:"fileid"`
+	Duration              pgtype.Float8 `json:"duration"`
+	Distance              pgtype.Float8 `json:"distance"`
+	Ascent                pgtype.Float8 `json:"ascent"`
+	Descent               pgtype.Float8 `json:"descent"`
+	Elevationdiff         pgtype.Float8 `json:"elevationdiff"`
+	Trails                pgtype.Text   `json:"trails"`
+	Rawdata               pgtype.Text   `json:"rawdata"`
+	Computedduration      pgtype.Float8 `json:"computedduration"`
+	Computeddistance      pgtype.Float8 `json:"computeddistance"`
+	Computedascent        pgtype.Float8 `json:"computedascent"`
+	Computeddescent       pgtype.Float8 `json:"computeddescent"`
+	Computedelevationdiff pgtype.Float8 `json:"computedelevationdiff"`
+	Mismatched            bool          `json:"mismatched"`
+	Mismatchedmetrics     pgtype.Text   `json:"mismatchedmetrics"`
+	Movingtime            pgtype.Float8 `json:"movingtime"`
+	Stoppedtime           pgtype.Float8 `json:"stoppedtime"`
+	Averagemovingspeed    pgtype.Float8 `json:"averagemovingspeed"`
+	Maxmovingspeed        pgtype.Float8 `json:"maxmovingspeed"`
+	Pace                  pgtype.Float8 `json:"pace"`
+	Simplifiedpolyline    pgtype.Text   `json:"simplifiedpolyline"`
+}
+
+type Trackpoint struct {
+	Recordid     string             `json:"recordid"`
+	Segmentindex int32              `json:"segmentindex"`
+	Sequence     int32              `json:"sequence"`
+	Latitude     float64            `json:"latitude"`
+	Longitude    float64            `json:"longitude"`
+	Elevation    pgtype.Float8      `json:"elevation"`
+	Recordedat   pgtype.Timestamptz `json:"recordedat"`
 }
 
 type User struct {
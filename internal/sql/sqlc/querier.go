@@ -13,14 +13,17 @@ import (
 type Querier interface {
 	BulkInsertFiles(ctx context.Context, arg []BulkInsertFilesParams) (int64, error)
 	BulkInsertRecord(ctx context.Context, arg []BulkInsertRecordParams) (int64, error)
+	BulkInsertTrackPoints(ctx context.Context, arg []BulkInsertTrackPointsParams) (int64, error)
 	DeleteFileById(ctx context.Context, id string) error
 	DeleteFileByName(ctx context.Context, filename string) error
 	DeleteRecordByFileId(ctx context.Context, fileid string) error
 	DeleteRecordById(ctx context.Context, id string) error
 	DeleteRecordsByUserId(ctx context.Context, userid string) error
+	DeleteTrackPointsByRecordId(ctx context.Context, recordid string) error
 	DeleteUser(ctx context.Context, id string) error
 	DropFiles(ctx context.Context) error
 	DropRecords(ctx context.Context) error
+	DropTrackPoints(ctx context.Context) error
 	DropUsers(ctx context.Context) error
 	GetFileById(ctx context.Context, id string) (File, error)
 	GetFileByName(ctx context.Context, filename string) (File, error)
@@ -29,10 +32,12 @@ type Querier interface {
 	GetRecordsByTrail(ctx context.Context, trails pgtype.Text) ([]Record, error)
 	GetRecordsByUserId(ctx context.Context, userid string) ([]Record, error)
 	GetRecordsOfUserOnTrail(ctx context.Context, arg GetRecordsOfUserOnTrailParams) ([]Record, error)
+	GetTrackPointsByRecordId(ctx context.Context, recordid string) ([]Trackpoint, error)
 	GetUserById(ctx context.Context, id string) (string, error)
 	InsertFile(ctx context.Context, arg InsertFileParams) (File, error)
 	InsertRecord(ctx context.Context, arg InsertRecordParams) (Record, error)
 	InsertUser(ctx context.Context, id string) error
+	ListFiles(ctx context.Context) ([]File, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -12,16 +12,29 @@ import (
 )
 
 type BulkInsertRecordParams struct {
-	ID            string        `json:"id"`
-	Userid        string        `json:"userid"`
-	Fileid        string        `json:"fileid"`
-	Duration      float32 `json:"duration"`
-	Distance      float32 `json:"distance"`
-	Ascent        float32 `json:"ascent"`
-	Descent       float32 `json:"descent"`
-	Elevationdiff float32 `json:"elevationdiff"`
-	Trails        string   `json:"trails"`
-	Rawdata       string   `json:"rawdata"`
+	ID                    string        `json:"id"`
+	Userid                string        `json:"userid"`
+	Fileid                string        `json:"fileid"`
+	Duration              float32       `json:"duration"`
+	Distance              float32       `json:"distance"`
+	Ascent                float32       `json:"ascent"`
+	Descent               float32       `json:"descent"`
+	Elevationdiff         float32       `json:"elevationdiff"`
+	Trails                string        `json:"trails"`
+	Rawdata               string        `json:"rawdata"`
+	Computedduration      pgtype.Float8 `json:"computedduration"`
+	Computeddistance      pgtype.Float8 `json:"computeddistance"`
+	Computedascent        pgtype.Float8 `json:"computedascent"`
+	Computeddescent       pgtype.Float8 `json:"computeddescent"`
+	Computedelevationdiff pgtype.Float8 `json:"computedelevationdiff"`
+	Mismatched            bool          `json:"mismatched"`
+	Mismatchedmetrics     pgtype.Text   `json:"mismatchedmetrics"`
+	Movingtime            pgtype.Float8 `json:"movingtime"`
+	Stoppedtime           pgtype.Float8 `json:"stoppedtime"`
+	Averagemovingspeed    pgtype.Float8 `json:"averagemovingspeed"`
+	Maxmovingspeed        pgtype.Float8 `json:"maxmovingspeed"`
+	Pace                  pgtype.Float8 `json:"pace"`
+	Simplifiedpolyline    pgtype.Text   `json:"simplifiedpolyline"`
 }
 
 const deleteRecordByFileId = `-- name: DeleteRecordByFileId :exec
@@ -61,7 +74,7 @@ func (q *Queries) DropRecords(ctx context.Context) error {
 }
 
 const getRecordByFileId = `-- name: GetRecordByFileId :one
-SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata FROM Records WHERE FileId = $1 LIMIT 1
+SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline FROM Records WHERE FileId = $1 LIMIT 1
 `
 
 func (q *Queries) GetRecordByFileId(ctx context.Context, fileid string) (Record, error) {
@@ -78,12 +91,25 @@ func (q *Queries) GetRecordByFileId(ctx context.Context, fileid string) (Record,
 		&i.Elevationdiff,
 		&i.Trails,
 		&i.Rawdata,
+		&i.Computedduration,
+		&i.Computeddistance,
+		&i.Computedascent,
+		&i.Computeddescent,
+		&i.Computedelevationdiff,
+		&i.Mismatched,
+		&i.Mismatchedmetrics,
+		&i.Movingtime,
+		&i.Stoppedtime,
+		&i.Averagemovingspeed,
+		&i.Maxmovingspeed,
+		&i.Pace,
+		&i.Simplifiedpolyline,
 	)
 	return i, err
 }
 
 const getRecordById = `-- name: GetRecordById :one
-SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata FROM Records WHERE Id = $1 LIMIT 1
+SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline FROM Records WHERE Id = $1 LIMIT 1
 `
 
 func (q *Queries) GetRecordById(ctx context.Context, id string) (Record, error) {
@@ -100,12 +126,25 @@ func (q *Queries) GetRecordById(ctx context.Context, id string) (Record, error)
 		&i.Elevationdiff,
 		&i.Trails,
 		&i.Rawdata,
+		&i.Computedduration,
+		&i.Computeddistance,
+		&i.Computedascent,
+		&i.Computeddescent,
+		&i.Computedelevationdiff,
+		&i.Mismatched,
+		&i.Mismatchedmetrics,
+		&i.Movingtime,
+		&i.Stoppedtime,
+		&i.Averagemovingspeed,
+		&i.Maxmovingspeed,
+		&i.Pace,
+		&i.Simplifiedpolyline,
 	)
 	return i, err
 }
 
 const getRecordsByTrail = `-- name: GetRecordsByTrail :many
-SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata FROM Records WHERE Trails = $1
+SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline FROM Records WHERE Trails = $1
 `
 
 func (q *Queries) GetRecordsByTrail(ctx context.Context, trails pgtype.Text) ([]Record, error) {
@@ -128,6 +167,48 @@ func (q *Queries) GetRecordsByTrail(ctx context.Context, trails pgtype.Text) ([]
 			&i.Elevationdiff,
 			&i.Trails,
 			&i.Rawdata,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
 		); err != nil {
 			return nil, err
 		}
@@ -140,7 +221,7 @@ func (q *Queries) GetRecordsByTrail(ctx context.Context, trails pgtype.Text) ([]
 }
 
 const getRecordsByUserId = `-- name: GetRecordsByUserId :many
-SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata FROM Records WHERE UserId = $1
+SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline FROM Records WHERE UserId = $1
 `
 
 func (q *Queries) GetRecordsByUserId(ctx context.Context, userid string) ([]Record, error) {
@@ -163,6 +244,48 @@ func (q *Queries) GetRecordsByUserId(ctx context.Context, userid string) ([]Reco
 			&i.Elevationdiff,
 			&i.Trails,
 			&i.Rawdata,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
 		); err != nil {
 			return nil, err
 		}
@@ -175,7 +298,7 @@ func (q *Queries) GetRecordsByUserId(ctx context.Context, userid string) ([]Reco
 }
 
 const getRecordsOfUserOnTrail = `-- name: GetRecordsOfUserOnTrail :many
-SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata FROM Records WHERE UserId = $1 AND Trails = $2
+SELECT id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline FROM Records WHERE UserId = $1 AND Trails = $2
 `
 
 type GetRecordsOfUserOnTrailParams struct {
@@ -203,6 +326,48 @@ func (q *Queries) GetRecordsOfUserOnTrail(ctx context.Context, arg GetRecordsOfU
 			&i.Elevationdiff,
 			&i.Trails,
 			&i.Rawdata,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Computedduration,
+			&i.Computeddistance,
+			&i.Computedascent,
+			&i.Computeddescent,
+			&i.Computedelevationdiff,
+			&i.Mismatched,
+			&i.Mismatchedmetrics,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
+			&i.Movingtime,
+			&i.Stoppedtime,
+			&i.Averagemovingspeed,
+			&i.Maxmovingspeed,
+			&i.Pace,
+			&i.Simplifiedpolyline,
+			&i.Simplifiedpolyline,
 		); err != nil {
 			return nil, err
 		}
@@ -216,23 +381,41 @@ func (q *Queries) GetRecordsOfUserOnTrail(ctx context.Context, arg GetRecordsOfU
 
 const insertRecord = `-- name: InsertRecord :one
 INSERT INTO Records (
-    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData
+    Id, UserId, FileId, Duration, Distance, Ascent, Descent, ElevationDiff, Trails, RawData,
+    ComputedDuration, ComputedDistance, ComputedAscent, ComputedDescent, ComputedElevationDiff,
+    Mismatched, MismatchedMetrics,
+    MovingTime, StoppedTime, AverageMovingSpeed, MaxMovingSpeed, Pace,
+    SimplifiedPolyline
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
-) RETURNING id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+    $18, $19, $20, $21, $22, $23
+) RETURNING id, userid, fileid, duration, distance, ascent, descent, elevationdiff, trails, rawdata, computedduration, computeddistance, computedascent, computeddescent, computedelevationdiff, mismatched, mismatchedmetrics, movingtime, stoppedtime, averagemovingspeed, maxmovingspeed, pace, simplifiedpolyline
 `
 
 type InsertRecordParams struct {
-	ID            string        `json:"id"`
-	Userid        string        `json:"userid"`
-	Fileid        string        `json:"fileid"`
-	Duration      pgtype.Float8 `json:"duration"`
-	Distance      pgtype.Float8 `json:"distance"`
-	Ascent        pgtype.Float8 `json:"ascent"`
-	Descent       pgtype.Float8 `json:"descent"`
-	Elevationdiff pgtype.Float8 `json:"elevationdiff"`
-	Trails        pgtype.Text   `json:"trails"`
-	Rawdata       pgtype.Text   `json:"rawdata"`
+	ID                    string        `json:"id"`
+	Userid                string        `json:"userid"`
+	Fileid                string        `json:"fileid"`
+	Duration              pgtype.Float8 `json:"duration"`
+	Distance              pgtype.Float8 `json:"distance"`
+	Ascent                pgtype.Float8 `json:"ascent"`
+	Descent               pgtype.Float8 `json:"descent"`
+	Elevationdiff         pgtype.Float8 `json:"elevationdiff"`
+	Trails                pgtype.Text   `json:"trails"`
+	Rawdata               pgtype.Text   `json:"rawdata"`
+	Computedduration      pgtype.Float8 `json:"computedduration"`
+	Computeddistance      pgtype.Float8 `json:"computeddistance"`
+	Computedascent        pgtype.Float8 `json:"computedascent"`
+	Computeddescent       pgtype.Float8 `json:"computeddescent"`
+	Computedelevationdiff pgtype.Float8 `json:"computedelevationdiff"`
+	Mismatched            bool          `json:"mismatched"`
+	Mismatchedmetrics     pgtype.Text   `json:"mismatchedmetrics"`
+	Movingtime            pgtype.Float8 `json:"movingtime"`
+	Stoppedtime           pgtype.Float8 `json:"stoppedtime"`
+	Averagemovingspeed    pgtype.Float8 `json:"averagemovingspeed"`
+	Maxmovingspeed        pgtype.Float8 `json:"maxmovingspeed"`
+	Pace                  pgtype.Float8 `json:"pace"`
+	Simplifiedpolyline    pgtype.Text   `json:"simplifiedpolyline"`
 }
 
 func (q *Queries) InsertRecord(ctx context.Context, arg InsertRecordParams) (Record, error) {
@@ -247,6 +430,19 @@ func (q *Queries) InsertRecord(ctx context.Context, arg InsertRecordParams) (Rec
 		arg.Elevationdiff,
 		arg.Trails,
 		arg.Rawdata,
+		arg.Computedduration,
+		arg.Computeddistance,
+		arg.Computedascent,
+		arg.Computeddescent,
+		arg.Computedelevationdiff,
+		arg.Mismatched,
+		arg.Mismatchedmetrics,
+		arg.Movingtime,
+		arg.Stoppedtime,
+		arg.Averagemovingspeed,
+		arg.Maxmovingspeed,
+		arg.Pace,
+		arg.Simplifiedpolyline,
 	)
 	var i Record
 	err := row.Scan(
@@ -260,6 +456,19 @@ func (q *Queries) InsertRecord(ctx context.Context, arg InsertRecordParams) (Rec
 		&i.Elevationdiff,
 		&i.Trails,
 		&i.Rawdata,
+		&i.Computedduration,
+		&i.Computeddistance,
+		&i.Computedascent,
+		&i.Computeddescent,
+		&i.Computedelevationdiff,
+		&i.Mismatched,
+		&i.Mismatchedmetrics,
+		&i.Movingtime,
+		&i.Stoppedtime,
+		&i.Averagemovingspeed,
+		&i.Maxmovingspeed,
+		&i.Pace,
+		&i.Simplifiedpolyline,
 	)
 	return i, err
 }
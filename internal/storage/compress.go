@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/compression"
+)
+
+// compressStaged replaces a finished staging file with a compressed copy and
+// returns the copy's path. The copy keeps PART_SUFFIX so an interrupted run
+// still cleans it up. Without compression the staging file is returned as is.
+func compressStaged(stagingPath, kind string) (compressedPath string, err error) {
+	ext := compression.Extension(kind)
+	if ext == "" {
+		return stagingPath, nil
+	}
+	compressedPath = strings.TrimSuffix(stagingPath, PART_SUFFIX) + ext + PART_SUFFIX
+
+	src, err := os.Open(stagingPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(compressedPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		dst.Close()
+		if err != nil {
+			os.Remove(compressedPath)
+		}
+	}()
+
+	writer, err := compression.NewWriter(dst, kind)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(writer, src); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	if err := dst.Sync(); err != nil {
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		return "", err
+	}
+
+	src.Close()
+	if err := os.Remove(stagingPath); err != nil {
+		return "", err
+	}
+	return compressedPath, nil
+}
+
+// decompressed reads a stored file as its uncompressed content. Closing it
+// closes the stored file too.
+type decompressed struct {
+	io.ReadCloser
+	stored io.Closer
+}
+
+func newDecompressed(stored io.ReadCloser) (io.ReadCloser, error) {
+	reader, err := compression.NewReader(stored)
+	if err != nil {
+		stored.Close()
+		return nil, err
+	}
+	return &decompressed{ReadCloser: reader, stored: stored}, nil
+}
+
+func (d *decompressed) Close() error {
+	d.ReadCloser.Close()
+	return d.stored.Close()
+}
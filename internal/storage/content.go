@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/compression"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/utils"
+)
+
+// ContentStore keeps each distinct GPX file once, at
+// installPath/<sha512[:2]>/<sha512>. An index maps file names to the hash of
+// their content, so records with identical tracks share one blob. The hash is
+// always of the uncompressed content.
+type ContentStore struct {
+	mu          sync.Mutex
+	installPath string
+	compression string
+	index       *index
+}
+
+func NewContentStore(installPath, compressionKind string) (*ContentStore, error) {
+	idx, err := openIndex(path.Join(installPath, INDEX_FILE))
+	if err != nil {
+		return nil, err
+	}
+	return &ContentStore{installPath: installPath, compression: compressionKind, index: idx}, nil
+}
+
+func (s *ContentStore) blobKey(hash string) (string, error) {
+	if len(hash) < 3 {
+		return "", fmt.Errorf("Invalid content hash %q", hash)
+	}
+	return path.Join(hash[:2], hash) + compression.Extension(s.compression), nil
+}
+
+func (s *ContentStore) blobPath(record *models.DataRecord) (string, error) {
+	hash, found := s.index.Get(record.FileName)
+	if !found {
+		return "", fmt.Errorf("%s: %w", record.FileName, fs.ErrNotExist)
+	}
+	key, err := s.blobKey(hash)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.installPath, key), nil
+}
+
+func (s *ContentStore) StagingPath(record *models.DataRecord) string {
+	return path.Join(s.installPath, record.FileName+PART_SUFFIX)
+}
+
+// Commit moves the staging file to the blob for its hash. When that blob
+// already exists the staging file is dropped. The record is only marked as a
+// duplicate when the blob belongs to another file name; downloading the same
+// unchanged file again is not a duplicate.
+func (s *ContentStore) Commit(ctx context.Context, record *models.DataRecord, stagingPath string) error {
+	hash := record.SHA512Sum
+	if hash == "" {
+		file, err := os.Open(stagingPath)
+		if err != nil {
+			return err
+		}
+		hash, err = utils.GenerateFileHash(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	key, err := s.blobKey(hash)
+	if err != nil {
+		return err
+	}
+	blobPath := path.Join(s.installPath, key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = os.Stat(blobPath)
+	switch {
+	case err == nil:
+		if err := os.Remove(stagingPath); err != nil {
+			return err
+		}
+		indexed, _ := s.index.Get(record.FileName)
+		record.Duplicate = indexed != hash
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(path.Dir(blobPath), 0755); err != nil {
+			return err
+		}
+		compressedPath, err := compressStaged(stagingPath, s.compression)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(compressedPath, blobPath); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	return s.index.Set(record.FileName, hash)
+}
+
+func (s *ContentStore) Open(ctx context.Context, record *models.DataRecord) (io.ReadCloser, error) {
+	blobPath, err := s.blobPath(record)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(blobPath)
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressed(file)
+}
+
+func (s *ContentStore) Stat(ctx context.Context, record *models.DataRecord) (int64, error) {
+	blobPath, err := s.blobPath(record)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(blobPath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *ContentStore) Key(record *models.DataRecord) string {
+	hash, found := s.index.Get(record.FileName)
+	if !found {
+		hash = record.SHA512Sum
+	}
+	key, err := s.blobKey(hash)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+func (s *ContentStore) Location(record *models.DataRecord) string {
+	key := s.Key(record)
+	if key == "" {
+		return path.Join(s.installPath, record.FileName)
+	}
+	return path.Join(s.installPath, key)
+}
+
+func (s *ContentStore) Close() error {
+	return s.index.Close()
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/jsonl"
+)
+
+const (
+	INDEX_FILE = "index.jsonl"
+)
+
+type indexEntry struct {
+	FileName  string `json:"file_name"`
+	SHA512Sum string `json:"sha512sum"`
+}
+
+// index maps file names to the hash of their content. Like the download
+// manifest it is an append-only JSON-lines log where the last line for a
+// file wins, compacted to one line per file each time it is opened.
+type index struct {
+	mu      sync.Mutex
+	log     *jsonl.Log[indexEntry]
+	entries map[string]string
+}
+
+func openIndex(indexPath string) (*index, error) {
+	log, logEntries, err := jsonl.Open(indexPath, func(entry indexEntry) string {
+		if entry.SHA512Sum == "" {
+			return ""
+		}
+		return entry.FileName
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string, len(logEntries))
+	for fileName, entry := range logEntries {
+		entries[fileName] = entry.SHA512Sum
+	}
+	return &index{log: log, entries: entries}, nil
+}
+
+func (i *index) Get(fileName string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	hash, found := i.entries[fileName]
+	return hash, found
+}
+
+func (i *index) Set(fileName, hash string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.entries[fileName] == hash {
+		return nil
+	}
+	i.entries[fileName] = hash
+	return i.log.Append(indexEntry{FileName: fileName, SHA512Sum: hash})
+}
+
+func (i *index) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.log.Close()
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+)
+
+const (
+	LAYOUT_FLAT   = "flat"
+	LAYOUT_USER   = "user"
+	LAYOUT_PREFIX = "prefix"
+	LAYOUT_MONTH  = "month"
+
+	// UNKNOWN_DIR holds files whose record lacks the field the layout shards
+	// by, e.g. a missing user ID or an unparsable RecordedAt.
+	UNKNOWN_DIR = "unknown"
+)
+
+var recordedAtFormats = []string{
+	time.RFC3339,
+	time.DateTime,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// Layout decides the directory a file is stored in, relative to the root of
+// the store, so large corpora are not kept in one flat directory.
+type Layout struct {
+	kind         string
+	prefixLength int
+}
+
+func NewLayout(cfg config.StorageConfig) Layout {
+	return Layout{kind: cfg.Layout, prefixLength: cfg.PrefixLength}
+}
+
+// Path returns the path of record's file relative to the root of the store.
+func (l Layout) Path(record *models.DataRecord) string {
+	return path.Join(l.dir(record), record.FileName)
+}
+
+func (l Layout) dir(record *models.DataRecord) string {
+	switch l.kind {
+	case LAYOUT_USER:
+		return safeDir(record.UserId)
+	case LAYOUT_PREFIX:
+		prefix := []rune(record.FileName)
+		if len(prefix) > l.prefixLength {
+			prefix = prefix[:l.prefixLength]
+		}
+		return safeDir(string(prefix))
+	case LAYOUT_MONTH:
+		recordedAt, ok := parseRecordedAt(record.RecordedAt)
+		if !ok {
+			return UNKNOWN_DIR
+		}
+		return recordedAt.Format("2006/01")
+	default:
+		return ""
+	}
+}
+
+// safeDir turns a record field into a single directory name.
+func safeDir(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return UNKNOWN_DIR
+	}
+	return name
+}
+
+func parseRecordedAt(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, format := range recordedAtFormats {
+		if recordedAt, err := time.Parse(format, value); err == nil {
+			return recordedAt, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/compression"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+)
+
+// LocalStore keeps GPX files on local disk under installPath, arranged in
+// directories by layout and optionally compressed. Downloads are staged at
+// the top of installPath.
+type LocalStore struct {
+	installPath string
+	layout      Layout
+	compression string
+}
+
+func NewLocal(installPath string, layout Layout, compressionKind string) *LocalStore {
+	return &LocalStore{installPath: installPath, layout: layout, compression: compressionKind}
+}
+
+func (s *LocalStore) filePath(record *models.DataRecord) string {
+	return path.Join(s.installPath, s.Key(record))
+}
+
+func (s *LocalStore) StagingPath(record *models.DataRecord) string {
+	return path.Join(s.installPath, record.FileName+PART_SUFFIX)
+}
+
+func (s *LocalStore) Commit(ctx context.Context, record *models.DataRecord, stagingPath string) error {
+	filePath := s.filePath(record)
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	compressedPath, err := compressStaged(stagingPath, s.compression)
+	if err != nil {
+		return err
+	}
+	return os.Rename(compressedPath, filePath)
+}
+
+func (s *LocalStore) Open(ctx context.Context, record *models.DataRecord) (io.ReadCloser, error) {
+	file, err := os.Open(s.filePath(record))
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressed(file)
+}
+
+func (s *LocalStore) Stat(ctx context.Context, record *models.DataRecord) (int64, error) {
+	info, err := os.Stat(s.filePath(record))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *LocalStore) Key(record *models.DataRecord) string {
+	return s.layout.Path(record) + compression.Extension(s.compression)
+}
+
+func (s *LocalStore) Location(record *models.DataRecord) string {
+	return s.filePath(record)
+}
+
+func (s *LocalStore) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/compression"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/objectstore"
+	"github.com/minio/minio-go/v7"
+)
+
+// ObjectStore keeps GPX files in an S3-compatible bucket. Downloads are
+// staged in a local directory and uploaded on Commit.
+type ObjectStore struct {
+	client      *minio.Client
+	bucket      string
+	keyTemplate string
+	layout      Layout
+	compression string
+	stagingPath string
+}
+
+func NewObjectStore(cfg config.StorageConfig, stagingPath string) (*ObjectStore, error) {
+	client, err := objectstore.New(cfg.ObjectStore)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectStore{
+		client:      client,
+		bucket:      cfg.Bucket,
+		keyTemplate: cfg.KeyTemplate,
+		layout:      NewLayout(cfg),
+		compression: cfg.Compression,
+		stagingPath: stagingPath,
+	}, nil
+}
+
+func (s *ObjectStore) Key(record *models.DataRecord) string {
+	return expandKey(s.keyTemplate, s.layout, record) + compression.Extension(s.compression)
+}
+
+func (s *ObjectStore) StagingPath(record *models.DataRecord) string {
+	return path.Join(s.stagingPath, record.FileName+PART_SUFFIX)
+}
+
+func (s *ObjectStore) Commit(ctx context.Context, record *models.DataRecord, stagingPath string) error {
+	stagingPath, err := compressStaged(stagingPath, s.compression)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(stagingPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObject(ctx, s.bucket, s.Key(record), file, info.Size(), minio.PutObjectOptions{
+		ContentType:     "application/gpx+xml",
+		ContentEncoding: contentEncoding(s.compression),
+	})
+	if err != nil {
+		return err
+	}
+
+	file.Close()
+	return os.Remove(stagingPath)
+}
+
+func (s *ObjectStore) Open(ctx context.Context, record *models.DataRecord) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.Key(record), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.wrapError(record, err)
+	}
+	// GetObject is lazy; Stat forces the request so a missing key is
+	// reported here rather than on the first Read.
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s.wrapError(record, err)
+	}
+	return newDecompressed(object)
+}
+
+func (s *ObjectStore) Stat(ctx context.Context, record *models.DataRecord) (int64, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, s.Key(record), minio.StatObjectOptions{})
+	if err != nil {
+		return 0, s.wrapError(record, err)
+	}
+	return info.Size, nil
+}
+
+func (s *ObjectStore) Location(record *models.DataRecord) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.Key(record))
+}
+
+func contentEncoding(kind string) string {
+	switch kind {
+	case compression.GZIP, compression.ZSTD:
+		return kind
+	default:
+		return ""
+	}
+}
+
+func (s *ObjectStore) Close() error {
+	return nil
+}
+
+func (s *ObjectStore) wrapError(record *models.DataRecord, err error) error {
+	if objectstore.IsNotFound(err) {
+		return fmt.Errorf("%s: %w", s.Location(record), fs.ErrNotExist)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/objectstore/objectstoretest"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test"><wpt lat="25.0330" lon="121.5654"><name>Start</name></wpt></gpx>
+`
+
+func newTestObjectStore(t *testing.T, server *objectstoretest.Server, compressionKind string) *ObjectStore {
+	t.Helper()
+	store, err := NewObjectStore(config.StorageConfig{
+		Type:        "s3",
+		Bucket:      "gpx",
+		KeyTemplate: "{user_id}/{file}",
+		Layout:      LAYOUT_FLAT,
+		Compression: compressionKind,
+		ObjectStore: server.Config(),
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func commitTestFile(t *testing.T, store *ObjectStore, record *models.DataRecord) string {
+	t.Helper()
+	stagingPath := store.StagingPath(record)
+	if err := os.WriteFile(stagingPath, []byte(testGPX), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Commit(context.Background(), record, stagingPath); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return stagingPath
+}
+
+func readAll(t *testing.T, store Store, record *models.DataRecord) string {
+	t.Helper()
+	file, err := store.Open(context.Background(), record)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestObjectStorePutStatGet(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	store := newTestObjectStore(t, server, "none")
+	record := &models.DataRecord{UserId: "user-1", FileName: "track.gpx"}
+
+	stagingPath := commitTestFile(t, store, record)
+	if _, err := os.Stat(stagingPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("staging file kept after upload: %v", err)
+	}
+
+	object, found := server.Get("gpx", "user-1/track.gpx")
+	if !found {
+		t.Fatal("object was not uploaded under user-1/track.gpx")
+	}
+	if object.ContentType != "application/gpx+xml" || object.ContentEncoding != "" {
+		t.Errorf("uploaded with Content-Type %q and Content-Encoding %q", object.ContentType, object.ContentEncoding)
+	}
+
+	size, err := store.Stat(context.Background(), record)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if size != int64(len(testGPX)) {
+		t.Errorf("Stat size = %d, want %d", size, len(testGPX))
+	}
+	if got := readAll(t, store, record); got != testGPX {
+		t.Errorf("Open returned %q, want %q", got, testGPX)
+	}
+	if got, want := store.Location(record), "s3://gpx/user-1/track.gpx"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestObjectStoreCompressed(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	store := newTestObjectStore(t, server, "zstd")
+	record := &models.DataRecord{UserId: "user-1", FileName: "track.gpx"}
+	commitTestFile(t, store, record)
+
+	object, found := server.Get("gpx", "user-1/track.gpx.zst")
+	if !found {
+		t.Fatal("object was not uploaded under user-1/track.gpx.zst")
+	}
+	if object.ContentEncoding != "zstd" {
+		t.Errorf("Content-Encoding = %q, want zstd", object.ContentEncoding)
+	}
+	if string(object.Data) == testGPX {
+		t.Error("object was uploaded uncompressed")
+	}
+	if got := readAll(t, store, record); got != testGPX {
+		t.Errorf("Open returned %q, want the decompressed file", got)
+	}
+}
+
+func TestObjectStoreMissingKey(t *testing.T) {
+	server := objectstoretest.NewServer(t)
+	store := newTestObjectStore(t, server, "none")
+	record := &models.DataRecord{UserId: "user-1", FileName: "missing.gpx"}
+
+	if _, err := store.Stat(context.Background(), record); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := store.Open(context.Background(), record); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open error = %v, want fs.ErrNotExist", err)
+	}
+}
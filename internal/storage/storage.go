@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/config"
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/models"
+)
+
+const (
+	PART_SUFFIX = ".part"
+)
+
+// Store is where downloaded GPX files are kept. Downloads are always written
+// to a local staging file first and handed to Commit once they are complete
+// and validated.
+type Store interface {
+	// StagingPath is the local file a download for record is written to.
+	StagingPath(record *models.DataRecord) string
+	// Commit moves a finished staging file into the store.
+	Commit(ctx context.Context, record *models.DataRecord, stagingPath string) error
+	// Open returns the stored content of record, decompressed.
+	Open(ctx context.Context, record *models.DataRecord) (io.ReadCloser, error)
+	// Stat returns the stored, possibly compressed, size of record, or an
+	// error wrapping fs.ErrNotExist when it has not been stored.
+	Stat(ctx context.Context, record *models.DataRecord) (int64, error)
+	// Key is where record is stored relative to the root of the store,
+	// i.e. its path under installPath or its object key.
+	Key(record *models.DataRecord) string
+	// Location describes where record is stored, for logging.
+	Location(record *models.DataRecord) string
+	// Close flushes anything the store keeps open.
+	Close() error
+}
+
+// expandKey fills the {file}, {user_id} and {path} placeholders of a key
+// template. {path} is the file's path under the configured layout.
+func expandKey(template string, layout Layout, record *models.DataRecord) string {
+	return strings.NewReplacer(
+		"{file}", record.FileName,
+		"{user_id}", record.UserId,
+		"{path}", layout.Path(record),
+	).Replace(template)
+}
+
+// New creates the store selected by cfg. Local stores keep files in
+// installPath; object stores use it for staging only.
+func New(cfg config.StorageConfig, installPath string) (Store, error) {
+	switch cfg.Type {
+	case "", "local":
+		if cfg.Deduplicate {
+			return NewContentStore(installPath, cfg.Compression)
+		}
+		return NewLocal(installPath, NewLayout(cfg), cfg.Compression), nil
+	case "s3":
+		return NewObjectStore(cfg, installPath)
+	default:
+		return nil, fmt.Errorf("Unknown storage type %q", cfg.Type)
+	}
+}
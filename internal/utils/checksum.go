@@ -6,27 +6,46 @@ import (
 	"encoding/hex"
 	"io"
 	"os"
+
+	"github.com/Maxxxxxx-x/gpx-downloader/internal/compression"
 )
 
+// GenerateFileHash hashes the uncompressed content of file, so a gzip or
+// zstd compressed file has the same hash as the original.
 func GenerateFileHash(file *os.File) (string, error) {
-    hasher := sha512.New()
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", err
+	}
 
-    if _, err := file.Seek(0, 0); err != nil {
-        return "", err
-    }
+	reader, err := compression.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
 
-	if _, err := io.Copy(hasher, file); err != nil {
+	return GenerateReaderHash(reader)
+}
+
+func GenerateReaderHash(reader io.Reader) (string, error) {
+	hasher := sha512.New()
+
+	if _, err := io.Copy(hasher, reader); err != nil {
 		return "", err
 	}
 
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+func GenerateHash(data []byte) string {
+	hash := sha512.Sum512(data)
+	return hex.EncodeToString(hash[:])
+}
+
 func CompareFileAndHash(file *os.File, hash string) (bool, error) {
 	fileHash, err := GenerateFileHash(file)
 	if err != nil {
 		return false, err
 	}
 
-	return subtle.ConstantTimeCompare([]byte(fileHash), []byte(hash)) == 0, nil
+	return subtle.ConstantTimeCompare([]byte(fileHash), []byte(hash)) == 1, nil
 }